@@ -5,9 +5,10 @@ go 1.21
 require (
 	github.com/JohannesKaufmann/html-to-markdown v1.6.0
 	github.com/PuerkitoBio/goquery v1.9.2
+	github.com/andybalholm/cascadia v1.3.2
 )
 
 require (
-	github.com/andybalholm/cascadia v1.3.2 // indirect
 	golang.org/x/net v0.25.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -1,5 +1,10 @@
 package data
 
+import (
+	"fmt"
+	"strings"
+)
+
 // LabMeta holds static metadata for one Learning Lab.
 // This mapping cannot be derived dynamically; it is hardcoded here.
 type LabMeta struct {
@@ -12,6 +17,60 @@ type LabMeta struct {
 	Status     string // "published" | "recorded, not yet published"
 }
 
+// Published reports whether the lab has been released, as opposed to
+// recorded but not yet published.
+func (l LabMeta) Published() bool {
+	return l.Status == "published"
+}
+
+// RecordingURL returns the YouTube watch URL for the lab's recording.
+func (l LabMeta) RecordingURL() string {
+	return "https://www.youtube.com/watch?v=" + l.VideoID
+}
+
+// RecordingURLAt returns a short YouTube link that starts playback at the
+// given offset in seconds, for citing a moment in the recording.
+func (l LabMeta) RecordingURLAt(seconds int) string {
+	return fmt.Sprintf("https://youtu.be/%s?t=%d", l.VideoID, seconds)
+}
+
+// LabPageURL returns the lab's page on edu.chainguard.dev, or "" for
+// old-format and unpublished labs, which have none.
+func (l LabMeta) LabPageURL() string {
+	if l.Era != "new-format" || !l.Published() {
+		return ""
+	}
+	return "https://edu.chainguard.dev/software-security/learning-labs/" + l.ID + "/"
+}
+
+// DeckURL returns the public PDF of the lab's slide deck, or "" unless the
+// lab is new-format, published, and has a deck.
+func (l LabMeta) DeckURL() string {
+	if l.DeckFile == "" {
+		return ""
+	}
+	return l.DeckPDFURL()
+}
+
+// DeckPDFURL returns where the lab's public deck PDF would be published, or
+// "" for old-format and unpublished labs. Unlike DeckURL it doesn't require
+// a mapped DeckFile, so the URL may 404.
+func (l LabMeta) DeckPDFURL() string {
+	if l.Era != "new-format" || !l.Published() {
+		return ""
+	}
+	return "https://edu.chainguard.dev/downloads/learning-lab-" + strings.TrimPrefix(l.ID, "ll") + ".pdf"
+}
+
+// GitHubURL returns the lab's directory in the chainguard-dev/edu repo, or ""
+// if it has none.
+func (l LabMeta) GitHubURL() string {
+	if l.GitHubID == "" {
+		return ""
+	}
+	return "https://github.com/chainguard-dev/edu/tree/main/content/software-security/learning-labs/" + l.GitHubID
+}
+
 // Labs is the authoritative ordered list of all Learning Labs, newest first.
 // Playlist ordering from yt-dlp is ignored; this slice is the source of truth.
 var Labs = []LabMeta{
@@ -212,3 +271,31 @@ const PlaylistURL = "https://www.youtube.com/playlist?list=PLLjvkjPNmuZmvi2ZDXic
 
 // GitHubBaseURL is the raw content URL prefix for GitHub lab guides.
 const GitHubBaseURL = "https://raw.githubusercontent.com/chainguard-dev/edu/main/content/software-security/learning-labs/"
+
+// Validate checks labs for entries sharing an ID or a VideoID. Either would
+// make two labs overwrite each other's caches (transcripts are cached by
+// VideoID, catalog entries by ID) and playlist metadata. The error names
+// every offending pair.
+func Validate(labs []LabMeta) error {
+	var problems []string
+	check := func(field string, key func(LabMeta) string) {
+		first := make(map[string]int)
+		for i, l := range labs {
+			k := key(l)
+			if k == "" {
+				continue
+			}
+			if j, ok := first[k]; ok {
+				problems = append(problems, fmt.Sprintf("%s %q used by both entry %d (%s) and entry %d (%s)", field, k, j, labs[j].ID, i, l.ID))
+				continue
+			}
+			first[k] = i
+		}
+	}
+	check("ID", func(l LabMeta) string { return l.ID })
+	check("VideoID", func(l LabMeta) string { return l.VideoID })
+	if len(problems) > 0 {
+		return fmt.Errorf("lab map has duplicates:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLabsHaveNoDuplicates(t *testing.T) {
+	if err := Validate(Labs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateReportsDuplicates(t *testing.T) {
+	labs := []LabMeta{
+		{ID: "ll202509", VideoID: "a"},
+		{ID: "ll202510", VideoID: "a"},
+		{ID: "ll202509", VideoID: "b"},
+	}
+	err := Validate(labs)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	for _, want := range []string{`ID "ll202509" used by both entry 0 (ll202509) and entry 2`, `VideoID "a" used by both entry 0 (ll202509) and entry 1 (ll202510)`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
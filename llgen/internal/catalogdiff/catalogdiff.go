@@ -0,0 +1,193 @@
+// Package catalogdiff compares two labs-catalog.json files and renders what
+// changed between them as a Markdown changelog, for stakeholders reviewing a
+// regenerated catalog. It is plain JSON comparison; no LLM is involved.
+package catalogdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// entry is one catalog lab: its id and every field as raw JSON.
+type entry struct {
+	id     string
+	title  string
+	fields map[string]json.RawMessage
+}
+
+// Change is one field that differs between two versions of a lab.
+type Change struct {
+	Field  string
+	Before string // rendered value, or "" when the field was absent
+	After  string
+}
+
+// LabChange lists the changed fields of a lab present in both catalogs.
+type LabChange struct {
+	ID, Title string
+	Changes   []Change
+}
+
+// Diff is the difference between two catalogs. Labs are listed in the order
+// of the catalog they appear in (the new one for Added and Changed).
+type Diff struct {
+	Added   []LabChange // Changes unset
+	Removed []LabChange // Changes unset
+	Changed []LabChange
+}
+
+// Empty reports whether the catalogs hold the same labs with the same fields.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare parses the old and new catalog JSON and diffs them by lab id.
+func Compare(oldJSON, newJSON []byte) (Diff, error) {
+	before, err := parse(oldJSON)
+	if err != nil {
+		return Diff{}, fmt.Errorf("old catalog: %w", err)
+	}
+	after, err := parse(newJSON)
+	if err != nil {
+		return Diff{}, fmt.Errorf("new catalog: %w", err)
+	}
+
+	byID := make(map[string]entry, len(before))
+	for _, e := range before {
+		byID[e.id] = e
+	}
+	inNew := make(map[string]bool, len(after))
+	var d Diff
+	for _, e := range after {
+		inNew[e.id] = true
+		old, ok := byID[e.id]
+		if !ok {
+			d.Added = append(d.Added, LabChange{ID: e.id, Title: e.title})
+			continue
+		}
+		if changes := compareFields(old.fields, e.fields); len(changes) > 0 {
+			d.Changed = append(d.Changed, LabChange{ID: e.id, Title: e.title, Changes: changes})
+		}
+	}
+	for _, e := range before {
+		if !inNew[e.id] {
+			d.Removed = append(d.Removed, LabChange{ID: e.id, Title: e.title})
+		}
+	}
+	return d, nil
+}
+
+func parse(data []byte) ([]entry, error) {
+	var catalog struct {
+		Labs []map[string]json.RawMessage `json:"labs"`
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, err
+	}
+	entries := make([]entry, 0, len(catalog.Labs))
+	for i, fields := range catalog.Labs {
+		var id, title string
+		_ = json.Unmarshal(fields["id"], &id)
+		_ = json.Unmarshal(fields["title"], &title)
+		if id == "" {
+			return nil, fmt.Errorf("lab %d has no id", i)
+		}
+		entries = append(entries, entry{id: id, title: title, fields: fields})
+	}
+	return entries, nil
+}
+
+// compareFields returns the fields whose values differ, sorted by name.
+// Values are compared after compacting, so formatting changes don't count.
+func compareFields(before, after map[string]json.RawMessage) []Change {
+	names := make(map[string]bool, len(after))
+	for k := range before {
+		names[k] = true
+	}
+	for k := range after {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, name := range sorted {
+		b, a := compact(before[name]), compact(after[name])
+		if b != a {
+			changes = append(changes, Change{Field: name, Before: render(b), After: render(a)})
+		}
+	}
+	return changes
+}
+
+func compact(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// render shows a compacted JSON value for humans: strings unquoted, arrays
+// of strings comma-separated, anything else as compact JSON.
+func render(v string) string {
+	var s string
+	if json.Unmarshal([]byte(v), &s) == nil {
+		return s
+	}
+	var list []string
+	if json.Unmarshal([]byte(v), &list) == nil {
+		return strings.Join(list, ", ")
+	}
+	return v
+}
+
+// Markdown renders d as a changelog titled with the two file names.
+func (d Diff) Markdown(oldName, newName string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Catalog changes: %s → %s\n\n", oldName, newName)
+	if d.Empty() {
+		sb.WriteString("No changes.\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "%d added, %d removed, %d changed.\n", len(d.Added), len(d.Removed), len(d.Changed))
+
+	if len(d.Added) > 0 {
+		sb.WriteString("\n## Added\n\n")
+		for _, l := range d.Added {
+			fmt.Fprintf(&sb, "- **%s** — %s\n", l.ID, l.Title)
+		}
+	}
+	if len(d.Removed) > 0 {
+		sb.WriteString("\n## Removed\n\n")
+		for _, l := range d.Removed {
+			fmt.Fprintf(&sb, "- **%s** — %s\n", l.ID, l.Title)
+		}
+	}
+	if len(d.Changed) > 0 {
+		sb.WriteString("\n## Changed\n")
+		for _, l := range d.Changed {
+			fmt.Fprintf(&sb, "\n### %s — %s\n\n", l.ID, l.Title)
+			for _, c := range l.Changes {
+				fmt.Fprintf(&sb, "- `%s`\n  - before: %s\n  - after: %s\n", c.Field, orNone(c.Before), orNone(c.After))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "_(none)_"
+	}
+	return s
+}
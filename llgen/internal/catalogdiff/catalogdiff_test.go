@@ -0,0 +1,37 @@
+package catalogdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	oldJSON := []byte(`{"labs": [
+		{"id": "ll202501", "title": "Old Lab", "difficulty": "beginner"},
+		{"id": "ll202502", "title": "Kept", "difficulty": "beginner", "topics": ["sbom", "vex"]}
+	]}`)
+	newJSON := []byte(`{"labs":[
+		{"id":"ll202502","title":"Kept","difficulty":"intermediate","topics":["sbom","vex"]},
+		{"id":"ll202503","title":"New Lab"}
+	]}`)
+
+	d, err := Compare(oldJSON, newJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Added) != 1 || d.Added[0].ID != "ll202503" {
+		t.Errorf("Added = %+v, want ll202503", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].ID != "ll202501" {
+		t.Errorf("Removed = %+v, want ll202501", d.Removed)
+	}
+	want := []Change{{Field: "difficulty", Before: "beginner", After: "intermediate"}}
+	if len(d.Changed) != 1 || len(d.Changed[0].Changes) != 1 || d.Changed[0].Changes[0] != want[0] {
+		t.Errorf("Changed = %+v, want only ll202502 difficulty (formatting differences ignored)", d.Changed)
+	}
+
+	md := d.Markdown("old.json", "new.json")
+	if !strings.Contains(md, "1 added, 1 removed, 1 changed.") {
+		t.Errorf("Markdown missing summary:\n%s", md)
+	}
+}
@@ -2,26 +2,72 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	anthropic "github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
+
+	"llgen/internal/atomicfile"
+	"llgen/internal/logging"
 )
 
+// Generator is the text-generation surface the generators depend on.
+// *Client implements it; tests substitute a canned implementation.
+type Generator interface {
+	Model() string
+	Generate(ctx context.Context, system, user string, maxTokens int64) (string, error)
+	GenerateWithThinking(ctx context.Context, system, user string, maxTokens int64, budgetTokens int64) (string, error)
+}
+
+var _ Generator = (*Client)(nil)
+
 // Client wraps the Anthropic SDK for simple text generation.
 type Client struct {
-	client anthropic.Client
-	model  string
+	client      anthropic.Client
+	model       string
+	thinkingDir string   // if set, thinking blocks are saved here; see SaveThinking
+	limiter     *Limiter // shared request-rate cap; nil means none
 }
 
-// NewClient creates a new Claude client with the given API key and model.
-func NewClient(apiKey, model string) *Client {
-	c := anthropic.NewClient(option.WithAPIKey(apiKey))
+// NewClient creates a new Claude client with the given API key and model,
+// sending requests through transport (nil for the default).
+func NewClient(apiKey, model string, transport http.RoundTripper) *Client {
+	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if transport != nil {
+		opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	c := anthropic.NewClient(opts...)
 	return &Client{client: c, model: model}
 }
 
+// Model returns the model name this client generates with.
+func (c *Client) Model() string { return c.model }
+
+// SaveThinking makes the client write the thinking blocks of each extended
+// thinking response to dir, one file per output file and lab as named by the
+// request context (see WithLabel). The returned text is unaffected.
+func (c *Client) SaveThinking(dir string) { c.thinkingDir = dir }
+
+// SetLimiter makes every request this client sends wait on l, which may be
+// shared with other clients.
+func (c *Client) SetLimiter(l *Limiter) { c.limiter = l }
+
+// Preflight verifies the API key and access to the client's model with a
+// models lookup, which costs no tokens. Call it before long-running work so
+// an invalid key fails in seconds rather than after collection.
+func (c *Client) Preflight(ctx context.Context) error {
+	if _, err := c.client.Models.Get(ctx, c.model, anthropic.ModelGetParams{}); err != nil {
+		return fmt.Errorf("claude preflight (model %s): %w", c.model, err)
+	}
+	return nil
+}
+
 // Generate sends a system + user prompt and returns the assistant's text response.
 // Retries once on error with a 5-second backoff.
 func (c *Client) Generate(ctx context.Context, system, user string, maxTokens int64) (string, error) {
@@ -34,6 +80,17 @@ func (c *Client) GenerateWithThinking(ctx context.Context, system, user string,
 	return c.generateWithRetry(ctx, system, user, maxTokens, true, budgetTokens)
 }
 
+// maxTokensCeiling caps how far a truncated response's MaxTokens is doubled.
+// It stays below the size at which the SDK refuses non-streaming requests.
+const maxTokensCeiling = 16384
+
+// ErrTruncated is returned when a response still hits max_tokens at
+// maxTokensCeiling.
+var ErrTruncated = errors.New("response truncated at max_tokens")
+
+// generateWithRetry retries once on error with a backoff. A response cut off
+// by max_tokens is instead retried immediately with MaxTokens doubled, up to
+// maxTokensCeiling, since repeating the same cap would truncate again.
 func (c *Client) generateWithRetry(ctx context.Context, system, user string, maxTokens int64, thinking bool, budgetTokens int64) (string, error) {
 	var lastErr error
 	for attempt := 0; attempt < 2; attempt++ {
@@ -43,8 +100,19 @@ func (c *Client) generateWithRetry(ctx context.Context, system, user string, max
 				return "", ctx.Err()
 			case <-time.After(5 * time.Second):
 			}
+			record(ctx, c.model, Usage{Retries: 1})
+		}
+		text, stop, err := c.doGenerate(ctx, system, user, maxTokens, thinking, budgetTokens)
+		for err == nil && stop == anthropic.StopReasonMaxTokens {
+			if maxTokens >= maxTokensCeiling {
+				return "", fmt.Errorf("claude.Generate: %w (%d tokens)", ErrTruncated, maxTokens)
+			}
+			maxTokens = min(maxTokens*2, maxTokensCeiling)
+			l, _ := ctx.Value(labelKey{}).(label)
+			logging.Infof("  claude [%s %s]: output truncated; retrying with max_tokens=%d\n", l.file, l.lab, maxTokens)
+			record(ctx, c.model, Usage{Retries: 1})
+			text, stop, err = c.doGenerate(ctx, system, user, maxTokens, thinking, budgetTokens)
 		}
-		text, err := c.doGenerate(ctx, system, user, maxTokens, thinking, budgetTokens)
 		if err == nil {
 			return text, nil
 		}
@@ -53,7 +121,8 @@ func (c *Client) generateWithRetry(ctx context.Context, system, user string, max
 	return "", lastErr
 }
 
-func (c *Client) doGenerate(ctx context.Context, system, user string, maxTokens int64, thinking bool, budgetTokens int64) (string, error) {
+// doGenerate makes one request and returns its text along with the stop reason.
+func (c *Client) doGenerate(ctx context.Context, system, user string, maxTokens int64, thinking bool, budgetTokens int64) (string, anthropic.StopReason, error) {
 	params := anthropic.MessageNewParams{
 		Model:     anthropic.Model(c.model),
 		MaxTokens: maxTokens,
@@ -73,16 +142,60 @@ func (c *Client) doGenerate(ctx context.Context, system, user string, maxTokens
 		}
 	}
 
+	l, _ := ctx.Value(labelKey{}).(label)
+	logging.Debugf("--- claude %s [%s %s] system prompt ---\n%s\n--- user prompt ---\n%s\n--- end prompt ---\n",
+		c.model, l.file, l.lab, system, user)
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return "", "", err
+	}
 	msg, err := c.client.Messages.New(ctx, params)
 	if err != nil {
-		return "", fmt.Errorf("claude.Generate: %w", err)
+		record(ctx, c.model, Usage{Calls: 1})
+		return "", "", fmt.Errorf("claude.Generate: %w", err)
 	}
+	logging.Debugf("  claude %s [%s %s]: %d input / %d output tokens, stop=%s\n",
+		c.model, l.file, l.lab, msg.Usage.InputTokens, msg.Usage.OutputTokens, msg.StopReason)
+	record(ctx, c.model, Usage{
+		Calls:        1,
+		InputTokens:  msg.Usage.InputTokens,
+		OutputTokens: msg.Usage.OutputTokens,
+	})
 
-	var sb strings.Builder
+	var sb, thought strings.Builder
 	for _, block := range msg.Content {
-		if block.Type == "text" {
+		switch block.Type {
+		case "text":
 			sb.WriteString(block.Text)
+		case "thinking":
+			thought.WriteString(block.Thinking + "\n\n")
+		case "redacted_thinking":
+			thought.WriteString("[redacted thinking block]\n\n")
+		}
+	}
+	if c.thinkingDir != "" && thought.Len() > 0 {
+		if err := c.writeThinking(l, thought.String()); err != nil {
+			logging.Infof("  Warning: %v\n", err)
 		}
 	}
-	return sb.String(), nil
+	return sb.String(), msg.StopReason, nil
+}
+
+// writeThinking saves thinking text to <thinkingDir>/<file>[_<lab>].thinking.md.
+func (c *Client) writeThinking(l label, text string) error {
+	name := l.file
+	if name == "" {
+		name = "unlabeled"
+	}
+	if l.lab != "" {
+		name += "_" + l.lab
+	}
+	if err := os.MkdirAll(c.thinkingDir, 0o755); err != nil {
+		return fmt.Errorf("mkdir thinking dir: %w", err)
+	}
+	path := filepath.Join(c.thinkingDir, name+".thinking.md")
+	if err := atomicfile.WriteFile(path, []byte(text), 0o644); err != nil {
+		return fmt.Errorf("save thinking: %w", err)
+	}
+	return nil
 }
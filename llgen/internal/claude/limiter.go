@@ -0,0 +1,55 @@
+package claude
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter caps the request rate of every client it is attached to (see
+// Client.SetLimiter), so concurrent generators sharing it stay under the
+// API's requests-per-minute limit instead of retrying through 429s. Requests
+// are spaced evenly: a bucket of one token refilled every minute/rpm. A nil
+// *Limiter does not limit.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // earliest start of the next request
+}
+
+// NewLimiter returns a Limiter allowing rpm requests per minute, or nil
+// (no limit) when rpm <= 0.
+func NewLimiter(rpm int) *Limiter {
+	if rpm <= 0 {
+		return nil
+	}
+	return &Limiter{interval: time.Minute / time.Duration(rpm)}
+}
+
+// Wait blocks until the caller may send a request, or until ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
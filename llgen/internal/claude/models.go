@@ -0,0 +1,34 @@
+package claude
+
+// KnownModels lists model names accepted by -model and -model-for without
+// -allow-unknown-model. Update when Anthropic releases new models.
+var KnownModels = []string{
+	"claude-opus-4-6",
+	"claude-opus-4-5",
+	"claude-opus-4-5-20251101",
+	"claude-opus-4-1",
+	"claude-opus-4-1-20250805",
+	"claude-opus-4-0",
+	"claude-opus-4-20250514",
+	"claude-sonnet-4-6",
+	"claude-sonnet-4-5",
+	"claude-sonnet-4-5-20250929",
+	"claude-sonnet-4-0",
+	"claude-sonnet-4-20250514",
+	"claude-haiku-4-5",
+	"claude-haiku-4-5-20251001",
+	"claude-3-7-sonnet-latest",
+	"claude-3-7-sonnet-20250219",
+	"claude-3-5-haiku-latest",
+	"claude-3-5-haiku-20241022",
+}
+
+// IsKnownModel reports whether name is in KnownModels.
+func IsKnownModel(name string) bool {
+	for _, m := range KnownModels {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"llgen/internal/logging"
+)
+
+// DefaultOpenAIURL is the API base used by -provider openai when
+// -provider-url is not set.
+const DefaultOpenAIURL = "https://api.openai.com/v1"
+
+var _ Generator = (*OpenAIClient)(nil)
+
+// OpenAIClient generates text through an OpenAI-compatible chat completions
+// API: OpenAI itself, or a local server such as Ollama
+// (http://localhost:11434/v1) or vLLM. Usage is recorded like Client's.
+type OpenAIClient struct {
+	baseURL string
+	apiKey  string // sent as a bearer token when set; local servers need none
+	model   string
+	http    *http.Client
+	limiter *Limiter // shared request-rate cap; nil means none
+}
+
+// NewOpenAIClient creates a client for the API at baseURL (e.g.
+// DefaultOpenAIURL) with the given key and model, sending requests through
+// transport (nil for the default).
+func NewOpenAIClient(baseURL, apiKey, model string, transport http.RoundTripper) *OpenAIClient {
+	return &OpenAIClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		http:    &http.Client{Timeout: 10 * time.Minute, Transport: transport},
+	}
+}
+
+// Model returns the model name this client generates with.
+func (c *OpenAIClient) Model() string { return c.model }
+
+// SetLimiter makes every request this client sends wait on l, which may be
+// shared with other clients.
+func (c *OpenAIClient) SetLimiter(l *Limiter) { c.limiter = l }
+
+// Preflight verifies the API is reachable and serves the client's model.
+func (c *OpenAIClient) Preflight(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/models/"+url.PathEscape(c.model), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("openai preflight (model %s): %w", c.model, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("openai preflight (model %s): %s: %s", c.model, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// Generate sends a system + user prompt and returns the assistant's text
+// response. Retries and max_tokens doubling work as in Client.Generate.
+func (c *OpenAIClient) Generate(ctx context.Context, system, user string, maxTokens int64) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(5 * time.Second):
+			}
+			record(ctx, c.model, Usage{Retries: 1})
+		}
+		text, finish, err := c.doGenerate(ctx, system, user, maxTokens)
+		for err == nil && finish == "length" {
+			if maxTokens >= maxTokensCeiling {
+				return "", fmt.Errorf("openai.Generate: %w (%d tokens)", ErrTruncated, maxTokens)
+			}
+			maxTokens = min(maxTokens*2, maxTokensCeiling)
+			l, _ := ctx.Value(labelKey{}).(label)
+			logging.Infof("  openai [%s %s]: output truncated; retrying with max_tokens=%d\n", l.file, l.lab, maxTokens)
+			record(ctx, c.model, Usage{Retries: 1})
+			text, finish, err = c.doGenerate(ctx, system, user, maxTokens)
+		}
+		if err == nil {
+			return text, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// GenerateWithThinking is Generate: the chat completions API has no portable
+// thinking budget, and reasoning models think without being asked.
+func (c *OpenAIClient) GenerateWithThinking(ctx context.Context, system, user string, maxTokens int64, budgetTokens int64) (string, error) {
+	return c.Generate(ctx, system, user, maxTokens)
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	MaxTokens int64         `json:"max_tokens"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// doGenerate makes one request and returns its text along with the finish
+// reason.
+func (c *OpenAIClient) doGenerate(ctx context.Context, system, user string, maxTokens int64) (string, string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: c.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		MaxTokens: maxTokens,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	l, _ := ctx.Value(labelKey{}).(label)
+	logging.Debugf("--- openai %s [%s %s] system prompt ---\n%s\n--- user prompt ---\n%s\n--- end prompt ---\n",
+		c.model, l.file, l.lab, system, user)
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return "", "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		record(ctx, c.model, Usage{Calls: 1})
+		return "", "", fmt.Errorf("openai.Generate: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		record(ctx, c.model, Usage{Calls: 1})
+		return "", "", fmt.Errorf("openai.Generate: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		record(ctx, c.model, Usage{Calls: 1})
+		return "", "", fmt.Errorf("openai.Generate: %s: %s", resp.Status, bytes.TrimSpace(raw))
+	}
+
+	var out chatResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		record(ctx, c.model, Usage{Calls: 1})
+		return "", "", fmt.Errorf("openai.Generate: parse response: %w", err)
+	}
+	logging.Debugf("  openai %s [%s %s]: %d input / %d output tokens\n",
+		c.model, l.file, l.lab, out.Usage.PromptTokens, out.Usage.CompletionTokens)
+	record(ctx, c.model, Usage{
+		Calls:        1,
+		InputTokens:  out.Usage.PromptTokens,
+		OutputTokens: out.Usage.CompletionTokens,
+	})
+	if len(out.Choices) == 0 {
+		return "", "", fmt.Errorf("openai.Generate: response has no choices")
+	}
+	return out.Choices[0].Message.Content, out.Choices[0].FinishReason, nil
+}
+
+func (c *OpenAIClient) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return req, nil
+}
@@ -0,0 +1,191 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage accumulates token and request counts.
+// The API bills extended thinking as output tokens and does not report it
+// separately, so OutputTokens includes any thinking.
+type Usage struct {
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Calls        int     `json:"calls"`     // API requests, including retries
+	Retries      int     `json:"retries"`   // requests beyond the first attempt
+	Fallbacks    int     `json:"fallbacks"` // generator-level fallbacks (e.g. thinking → standard)
+	CostUSD      float64 `json:"estimated_cost_usd"`
+}
+
+func (u *Usage) add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.Calls += o.Calls
+	u.Retries += o.Retries
+	u.Fallbacks += o.Fallbacks
+	u.CostUSD += o.CostUSD
+}
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// DefaultPricing holds list prices for the models llgen is normally run with.
+// Override with -pricing-file when prices change.
+var DefaultPricing = map[string]Price{
+	"claude-opus-4-6":   {InputPerMTok: 5, OutputPerMTok: 25},
+	"claude-opus-4-5":   {InputPerMTok: 5, OutputPerMTok: 25},
+	"claude-opus-4-1":   {InputPerMTok: 15, OutputPerMTok: 75},
+	"claude-opus-4-0":   {InputPerMTok: 15, OutputPerMTok: 75},
+	"claude-sonnet-4-6": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-sonnet-4-5": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-sonnet-4-0": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-haiku-4-5":  {InputPerMTok: 1, OutputPerMTok: 5},
+}
+
+// LoadPricing returns DefaultPricing overlaid with the JSON model→Price map
+// in path. An empty path returns the defaults.
+func LoadPricing(path string) (map[string]Price, error) {
+	pricing := make(map[string]Price, len(DefaultPricing))
+	for k, v := range DefaultPricing {
+		pricing[k] = v
+	}
+	if path == "" {
+		return pricing, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pricing file: %w", err)
+	}
+	var overrides map[string]Price
+	if err := json.Unmarshal(b, &overrides); err != nil {
+		return nil, fmt.Errorf("parse pricing file %s: %w", path, err)
+	}
+	for k, v := range overrides {
+		pricing[k] = v
+	}
+	return pricing, nil
+}
+
+type usageKey struct {
+	file, lab, model string
+}
+
+// Tracker records usage attributed to the output file and lab named by the
+// request context (see WithLabel). Safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	records map[usageKey]*Usage
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{records: make(map[usageKey]*Usage)}
+}
+
+type trackerKey struct{}
+type labelKey struct{}
+
+type label struct{ file, lab string }
+
+// WithTracker returns a context whose Claude calls are recorded in t.
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// WithLabel attributes Claude calls made with ctx to an output file and,
+// optionally, a lab ID.
+func WithLabel(ctx context.Context, file, lab string) context.Context {
+	return context.WithValue(ctx, labelKey{}, label{file: file, lab: lab})
+}
+
+// RecordFallback notes that a generator fell back to a second strategy.
+func RecordFallback(ctx context.Context) {
+	record(ctx, "", Usage{Fallbacks: 1})
+}
+
+func record(ctx context.Context, model string, u Usage) {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	if t == nil {
+		return
+	}
+	l, _ := ctx.Value(labelKey{}).(label)
+	key := usageKey{file: l.file, lab: l.lab, model: model}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec, ok := t.records[key]
+	if !ok {
+		rec = &Usage{}
+		t.records[key] = rec
+	}
+	rec.add(u)
+}
+
+// FileUsage is the usage for one output file, broken down by lab.
+type FileUsage struct {
+	Total Usage            `json:"total"`
+	Labs  map[string]Usage `json:"labs,omitempty"`
+}
+
+// UsageReport is the serialized form of usage-report.json.
+type UsageReport struct {
+	GeneratedAt    string               `json:"generated_at"`
+	Total          Usage                `json:"total"`
+	Files          map[string]FileUsage `json:"files"`
+	Models         map[string]Usage     `json:"models"`
+	UnpricedModels []string             `json:"unpriced_models,omitempty"`
+}
+
+// Report summarizes recorded usage, estimating cost from pricing.
+func (t *Tracker) Report(pricing map[string]Price) UsageReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := UsageReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Files:       make(map[string]FileUsage),
+		Models:      make(map[string]Usage),
+	}
+	unpriced := map[string]bool{}
+
+	for key, rec := range t.records {
+		u := *rec
+		if key.model != "" {
+			if p, ok := pricing[key.model]; ok {
+				u.CostUSD = float64(u.InputTokens)*p.InputPerMTok/1e6 + float64(u.OutputTokens)*p.OutputPerMTok/1e6
+			} else {
+				unpriced[key.model] = true
+			}
+			m := r.Models[key.model]
+			m.add(u)
+			r.Models[key.model] = m
+		}
+
+		f := r.Files[key.file]
+		f.Total.add(u)
+		if key.lab != "" {
+			if f.Labs == nil {
+				f.Labs = make(map[string]Usage)
+			}
+			l := f.Labs[key.lab]
+			l.add(u)
+			f.Labs[key.lab] = l
+		}
+		r.Files[key.file] = f
+		r.Total.add(u)
+	}
+
+	for m := range unpriced {
+		r.UnpricedModels = append(r.UnpricedModels, m)
+	}
+	sort.Strings(r.UnpricedModels)
+	return r
+}
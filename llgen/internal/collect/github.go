@@ -1,37 +1,66 @@
 package collect
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	"llgen/internal/atomicfile"
 	"llgen/internal/config"
 )
 
+// httpClient sends GitHub guide and deck PDF requests; see UseTransport.
+var httpClient = http.DefaultClient
+
+// UseTransport routes collect's HTTP requests through t, e.g. one from
+// NewTransport for -proxy. Call it before collection starts.
+func UseTransport(t http.RoundTripper) { httpClient = &http.Client{Transport: t} }
+
+// NewTransport returns an HTTP transport that sends requests through proxy,
+// or, when proxy is "", through HTTP_PROXY/HTTPS_PROXY as limited by
+// NO_PROXY, like http.DefaultTransport.
+func NewTransport(proxy string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q (want e.g. http://proxy.example.com:3128)", proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return t, nil
+}
+
 // FetchGitHubGuide fetches the lab guide markdown from the chainguard-dev/edu GitHub repo.
 // URL pattern: https://raw.githubusercontent.com/chainguard-dev/edu/main/content/software-security/learning-labs/{id}.md
 //
 // Returns ("", nil) gracefully on 404 (old-format labs or ll202601 which has no guide yet).
 // Caches result to <cacheDir>/github/<id>.md.
-// Skips fetch if cached file exists (unless cfg.Force).
-func FetchGitHubGuide(cfg *config.Config, id string) (string, error) {
+// Skips fetch if cached file exists (unless the lab is forced).
+func FetchGitHubGuide(ctx context.Context, cfg *config.Config, id string) (string, error) {
 	cachePath := filepath.Join(cfg.GitHubCacheDir(), id+".md")
 
-	if !cfg.Force {
+	if !cfg.ForceCollectLab(id) {
 		if content, err := os.ReadFile(cachePath); err == nil {
 			return string(content), nil
 		}
 	}
+	if cfg.Offline {
+		return "", fmt.Errorf("GitHub guide %s: %w", id, ErrOffline)
+	}
 
 	if err := os.MkdirAll(cfg.GitHubCacheDir(), 0o755); err != nil {
 		return "", fmt.Errorf("mkdir github cache: %w", err)
 	}
 
 	url := "https://raw.githubusercontent.com/chainguard-dev/edu/main/content/software-security/learning-labs/" + id + ".md"
-	content, err := fetchWithRetry(url, 2)
+	content, err := fetchWithRetry(ctx, cfg.UserAgent, url, 2)
 	if err != nil {
 		return "", err
 	}
@@ -39,21 +68,39 @@ func FetchGitHubGuide(cfg *config.Config, id string) (string, error) {
 		return "", nil // 404
 	}
 
-	if err := os.WriteFile(cachePath, []byte(content), 0o644); err != nil {
+	if err := atomicfile.WriteFile(cachePath, []byte(content), 0o644); err != nil {
 		return "", fmt.Errorf("write github cache %s: %w", cachePath, err)
 	}
 	return content, nil
 }
 
+// ReadGitHubGuide returns the cached guide for id, or "" when none is cached,
+// as after a 404. It never fetches: FetchGitHubGuide fills the cache during
+// collection.
+func ReadGitHubGuide(cfg *config.Config, id string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(cfg.GitHubCacheDir(), id+".md"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read github cache: %w", err)
+	}
+	return string(content), nil
+}
+
 // fetchWithRetry performs an HTTP GET with one retry on network error.
 // Returns ("", nil) on 404.
-func fetchWithRetry(url string, maxAttempts int) (string, error) {
+func fetchWithRetry(ctx context.Context, userAgent, url string, maxAttempts int) (string, error) {
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
-			time.Sleep(2 * time.Second)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
 		}
-		content, err := httpGet(url)
+		content, err := httpGet(ctx, userAgent, url)
 		if err == nil {
 			return content, nil
 		}
@@ -67,8 +114,15 @@ func fetchWithRetry(url string, maxAttempts int) (string, error) {
 
 var errNotFound = fmt.Errorf("not found")
 
-func httpGet(url string) (string, error) {
-	resp, err := http.Get(url) //nolint:gosec // URL is constructed from trusted data
+func httpGet(ctx context.Context, userAgent, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("http get %s: %w", url, err)
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	resp, err := httpClient.Do(req) //nolint:gosec // URL is constructed from trusted data
 	if err != nil {
 		return "", fmt.Errorf("http get %s: %w", url, err)
 	}
@@ -0,0 +1,77 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"llgen/internal/atomicfile"
+	"llgen/internal/config"
+)
+
+// ParsePDF extracts text from a PDF deck, one SlideText per page, using
+// poppler's pdftotext at binPath. Pages without text (e.g. full-bleed images)
+// are skipped, so SlideNum may have gaps.
+func ParsePDF(ctx context.Context, binPath, path string) ([]SlideText, error) {
+	if _, err := exec.LookPath(binPath); err != nil {
+		return nil, fmt.Errorf("pdftotext not found at %s — install with: brew install poppler", binPath)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binPath, "-layout", "-enc", "UTF-8", path, "-")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("pdftotext %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	// pdftotext ends each page with a form feed.
+	var slides []SlideText
+	for i, page := range strings.Split(string(out), "\f") {
+		var lines []string
+		for _, line := range strings.Split(page, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			slides = append(slides, SlideText{SlideNum: i + 1, Lines: lines})
+		}
+	}
+	return slides, nil
+}
+
+// FetchDeckPDF downloads the lab's public deck PDF from url and returns the
+// path of the cached copy, <cacheDir>/decks/<id>.pdf. Skips the fetch if the
+// cached file exists (unless the lab is forced). Returns ("", nil) on 404:
+// not every published lab has a public deck.
+func FetchDeckPDF(ctx context.Context, cfg *config.Config, id, url string) (string, error) {
+	cachePath := filepath.Join(cfg.DeckCacheDir(), id+".pdf")
+
+	if !cfg.ForceCollectLab(id) {
+		if _, err := os.Stat(cachePath); err == nil {
+			return cachePath, nil
+		}
+	}
+	if cfg.Offline {
+		return "", fmt.Errorf("deck PDF %s: %w", id, ErrOffline)
+	}
+
+	if err := os.MkdirAll(cfg.DeckCacheDir(), 0o755); err != nil {
+		return "", fmt.Errorf("mkdir deck cache: %w", err)
+	}
+	content, err := fetchWithRetry(ctx, cfg.UserAgent, url, 2)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return "", nil // 404
+	}
+	if err := atomicfile.WriteFile(cachePath, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("write deck cache %s: %w", cachePath, err)
+	}
+	return cachePath, nil
+}
@@ -0,0 +1,44 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"llgen/data"
+	"llgen/internal/atomicfile"
+	"llgen/internal/config"
+)
+
+// FetchThumbnail downloads the lab's YouTube thumbnail to
+// <outputDir>/thumbnails/<id>.jpg and returns that path. Skips the fetch if
+// the file exists (unless the lab is forced). Returns ("", nil) on 404, as
+// for videos that were removed.
+func FetchThumbnail(ctx context.Context, cfg *config.Config, lab data.LabMeta) (string, error) {
+	path := filepath.Join(cfg.ThumbnailsDir(), lab.ID+".jpg")
+
+	if !cfg.ForceCollectLab(lab.ID) {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	if cfg.Offline {
+		return "", fmt.Errorf("thumbnail %s: %w", lab.VideoID, ErrOffline)
+	}
+
+	if err := os.MkdirAll(cfg.ThumbnailsDir(), 0o755); err != nil {
+		return "", fmt.Errorf("mkdir thumbnails: %w", err)
+	}
+	content, err := fetchWithRetry(ctx, cfg.UserAgent, "https://img.youtube.com/vi/"+lab.VideoID+"/hqdefault.jpg", 2)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return "", nil // 404
+	}
+	if err := atomicfile.WriteFile(path, []byte(content), cfg.FileMode); err != nil {
+		return "", fmt.Errorf("write thumbnail %s: %w", path, err)
+	}
+	return path, nil
+}
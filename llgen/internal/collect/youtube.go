@@ -3,6 +3,8 @@ package collect
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +12,7 @@ import (
 	"strings"
 
 	"llgen/data"
+	"llgen/internal/atomicfile"
 	"llgen/internal/config"
 )
 
@@ -19,23 +22,47 @@ type VideoInfo struct {
 	UploadDate string // YYYYMMDD
 }
 
+// ErrOffline is returned under -offline when data is not cached and would
+// have to be fetched from the network.
+var ErrOffline = errors.New("not cached and -offline forbids network access")
+
 // FetchPlaylistInfo calls yt-dlp to list playlist metadata without downloading anything.
 // Returns a map of videoID → VideoInfo. Non-fatal on yt-dlp failure.
-func FetchPlaylistInfo(cfg *config.Config) (map[string]VideoInfo, error) {
+// The listing is cached so -offline runs can reuse the last successful fetch.
+func FetchPlaylistInfo(ctx context.Context, cfg *config.Config) (map[string]VideoInfo, error) {
+	if cfg.Offline {
+		out, err := os.ReadFile(cfg.PlaylistCacheFile())
+		if err != nil {
+			return nil, fmt.Errorf("playlist info: %w", ErrOffline)
+		}
+		return parsePlaylist(out)
+	}
+
 	if err := checkYtDlp(cfg.YtDlpPath); err != nil {
 		return nil, err
 	}
 
-	cmd := exec.Command(cfg.YtDlpPath,
+	cmd := exec.CommandContext(ctx, cfg.YtDlpPath, ytDlpArgs(cfg,
 		"--flat-playlist",
 		"--print", "%(id)s\t%(title)s\t%(upload_date)s",
 		data.PlaylistURL,
-	)
+	)...)
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("yt-dlp playlist fetch: %w", err)
 	}
 
+	if err := os.MkdirAll(cfg.CacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", cfg.CacheDir, err)
+	}
+	if err := atomicfile.WriteFile(cfg.PlaylistCacheFile(), out, 0o644); err != nil {
+		return nil, fmt.Errorf("write playlist cache: %w", err)
+	}
+	return parsePlaylist(out)
+}
+
+// parsePlaylist parses yt-dlp's id\ttitle\tupload_date lines.
+func parsePlaylist(out []byte) (map[string]VideoInfo, error) {
 	result := make(map[string]VideoInfo)
 	scanner := bufio.NewScanner(bytes.NewReader(out))
 	for scanner.Scan() {
@@ -54,20 +81,24 @@ func FetchPlaylistInfo(cfg *config.Config) (map[string]VideoInfo, error) {
 }
 
 // DownloadTranscript downloads the auto-generated English VTT transcript for a lab's video.
-// Skips download if the VTT file already exists (unless cfg.Force).
+// Skips download if the VTT file already exists (unless the lab is forced).
 // Also downloads the video description file (--write-description).
+// The yt-dlp process is killed if ctx is cancelled.
 //
 // Output files are written directly to cfg.CacheDir (flat layout):
 //
 //	<cacheDir>/<videoID>.en.vtt
 //	<cacheDir>/<videoID>.description
-func DownloadTranscript(cfg *config.Config, lab data.LabMeta) error {
+func DownloadTranscript(ctx context.Context, cfg *config.Config, lab data.LabMeta) error {
 	vttPath := filepath.Join(cfg.CacheDir, lab.VideoID+".en.vtt")
-	if !cfg.Force {
+	if !cfg.ForceCollectLab(lab.ID) {
 		if _, err := os.Stat(vttPath); err == nil {
 			return nil // already cached
 		}
 	}
+	if cfg.Offline {
+		return fmt.Errorf("transcript %s: %w", lab.VideoID, ErrOffline)
+	}
 
 	if err := checkYtDlp(cfg.YtDlpPath); err != nil {
 		return err
@@ -77,7 +108,7 @@ func DownloadTranscript(cfg *config.Config, lab data.LabMeta) error {
 		return fmt.Errorf("mkdir %s: %w", cfg.CacheDir, err)
 	}
 
-	cmd := exec.Command(cfg.YtDlpPath,
+	cmd := exec.CommandContext(ctx, cfg.YtDlpPath, ytDlpArgs(cfg,
 		"--write-auto-sub",
 		"--sub-lang", "en",
 		"--sub-format", "vtt",
@@ -86,7 +117,7 @@ func DownloadTranscript(cfg *config.Config, lab data.LabMeta) error {
 		"-o", "%(id)s",
 		"--paths", cfg.CacheDir,
 		"https://www.youtube.com/watch?v="+lab.VideoID,
-	)
+	)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -95,9 +126,70 @@ func DownloadTranscript(cfg *config.Config, lab data.LabMeta) error {
 	return nil
 }
 
+// ytDlpArgs prefixes args with --proxy when -proxy is set. Otherwise yt-dlp
+// reads HTTP_PROXY/HTTPS_PROXY from the inherited environment itself.
+func ytDlpArgs(cfg *config.Config, args ...string) []string {
+	if cfg.Proxy == "" {
+		return args
+	}
+	return append([]string{"--proxy", cfg.Proxy}, args...)
+}
+
 func checkYtDlp(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return fmt.Errorf("yt-dlp not found at %s — install with: brew install yt-dlp", path)
 	}
 	return nil
 }
+
+// ProbeResult is the outcome of resolving one video without downloading it.
+type ProbeResult struct {
+	Title   string // video title when it resolves
+	Problem string // "" when the video resolves; else "private", "removed", "geoblocked", "age-restricted", "members-only" or "error"
+	Detail  string // yt-dlp's error message
+}
+
+// ProbeVideo checks that videoID still resolves by asking yt-dlp for its
+// title with --simulate, which fetches no captions or media. It is much
+// faster than DownloadTranscript for catching labmap drift.
+func ProbeVideo(ctx context.Context, cfg *config.Config, videoID string) ProbeResult {
+	if err := checkYtDlp(cfg.YtDlpPath); err != nil {
+		return ProbeResult{Problem: "error", Detail: err.Error()}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.YtDlpPath, ytDlpArgs(cfg,
+		"--simulate",
+		"--skip-download",
+		"--no-warnings",
+		"--print", "title",
+		"https://www.youtube.com/watch?v="+videoID,
+	)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+		return ProbeResult{Problem: classifyProbe(detail), Detail: detail}
+	}
+	return ProbeResult{Title: strings.TrimSpace(stdout.String())}
+}
+
+// classifyProbe maps a yt-dlp error message to a ProbeResult.Problem.
+func classifyProbe(msg string) string {
+	m := strings.ToLower(msg)
+	switch {
+	case strings.Contains(m, "private video"):
+		return "private"
+	case strings.Contains(m, "country") || strings.Contains(m, "geo"):
+		return "geoblocked"
+	case strings.Contains(m, "confirm your age") || strings.Contains(m, "age-restricted"):
+		return "age-restricted"
+	case strings.Contains(m, "members-only") || strings.Contains(m, "join this channel"):
+		return "members-only"
+	case strings.Contains(m, "removed") || strings.Contains(m, "video unavailable") || strings.Contains(m, "does not exist") || strings.Contains(m, "terminated"):
+		return "removed"
+	}
+	return "error"
+}
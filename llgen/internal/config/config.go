@@ -4,41 +4,279 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"llgen/internal/claude"
 )
 
+// OutputFiles lists the files llgen can generate, in dependency order.
+var OutputFiles = []string{
+	"learning-labs-index.md",
+	"learning-labs-index.json",
+	"labs-catalog.json",
+	"labs-embeddings.json",
+	"recommender-system-prompt.md",
+}
+
+// Subcommands recognized as the first argument. With no subcommand llgen
+// runs the generation pipeline.
+var Subcommands = []string{"query", "diff-catalog", "check-videos", "compare"}
+
 // Config holds all runtime configuration parsed from CLI flags.
 type Config struct {
-	OutputDir  string
-	CacheDir   string
-	Force      bool
-	Only       string
-	Lab        string
-	Model      string
-	YtDlpPath  string
-	DecksDir   string
+	Command string   // subcommand, or "" for the generation pipeline
+	Args    []string // positional arguments after flags
+
+	ConfigFile string
+
+	OutputDir string
+	FileMode  os.FileMode // permissions of the generated files written to OutputDir
+
+	CacheDir          string
+	Force             bool     // both phases; see ForceCollect and ForceGenerate
+	ForceCollect      bool     // re-fetch transcripts and guides only
+	ForceGenerate     bool     // regenerate LLM output from the cached corpus only
+	Offline           bool     // collect from caches only; a cache miss is an error
+	NoLLM             bool     // collect, build corpora and write deterministic outputs only
+	CorpusStats       bool     // collect, build corpora and print their estimated token counts only
+	Plan              bool     // list cache files and whether each would be reused, then stop
+	Explain           bool     // query: add the reasoning behind the recommendation
+	DumpCorpus        bool     // write each lab's prompt-ready corpus to CorpusDumpDir
+	SaveThinking      string   // directory for extended-thinking transcripts; "" disables
+	EventsFile        string   // JSON-lines lifecycle event stream; "" disables
+	Only              []string // output files to regenerate; empty means all
+	Lab               string
+	SinceLab          string
+	Sample            int             // process this many randomly chosen labs; 0 = all
+	Seed              int64           // -sample seed; 0 picks one from the clock
+	ForceLabs         map[string]bool // labs forced individually (e.g. by -since-lab)
+	Model             string
+	AllowUnknownModel bool
+	ModelFor          map[string]string // output filename → model override
+	Provider          string            // "anthropic" or "openai" (any OpenAI-compatible API)
+	ProviderURL       string            // API base URL for -provider openai
+	YtDlpPath         string
+	Proxy             string // proxy URL for all HTTP requests and yt-dlp; "" uses HTTP(S)_PROXY
+	UserAgent         string
+	DecksDir          string
+	PDFDecks          bool // fall back to the public deck PDF when no local PPTX is available
+	Thumbnails        bool // download video thumbnails and show them in the index table
+	PdftotextPath     string
+	Concurrency       int
+	DownloadWorkers   int // concurrent yt-dlp transcript downloads
+	ClaudeRPM         int // requests per minute across all generation calls; 0 = unlimited
+	Timeout           time.Duration
+
+	CatalogSchemaFile  string // overrides the built-in catalog schema
+	CatalogExampleFile string // overrides the built-in few-shot catalog entry
+	CaveatsFile        string // Markdown caveats merged into the recommender's built-in ones
+	IncludeUnpublished bool   // false drops "recorded, not yet published" labs from every output
+	PromptsDir         string // <name>.tmpl files overriding the embedded system prompts
+	CatalogFormat      string // "json", or "ndjson" to also write labs-catalog.ndjson
+	EmbedModel         string
+	ExcerptHead        int    // transcript chars sent from the start in catalog prompts
+	ExcerptTail        int    // transcript chars sent from the end in catalog prompts
+	MaxInputTokens     int    // estimated prompt token budget per catalog entry; 0 disables
+	MinTranscriptWords int    // transcripts shorter than this are dropped as degraded; 0 disables
+	VTTDedup           string // caption dedup strategy: auto, rolling, none or fuzzy
+	Timecodes          bool   // send timecoded transcripts and ask for youtu.be deep-link citations
+	VideoDescription   bool   // send the cached video description as a source for instructor and date
+	PricingFile        string // JSON model → per-MTok prices for usage-report.json
+	WorkedExamples     int
+	SkipPreflight      bool
+	Verbose            bool
+	Quiet              bool
 }
 
 // Parse parses CLI flags and returns a Config. Exits on error.
 func Parse() *Config {
-	cfg := &Config{}
+	cfg := &Config{ModelFor: map[string]string{}, ForceLabs: map[string]bool{}, FileMode: 0o644}
 
+	flag.StringVar(&cfg.ConfigFile, "config", "", "Config file of flag defaults (default: llgen.yaml, llgen.yml or llgen.toml in the working dir, if present)")
 	flag.StringVar(&cfg.OutputDir, "output-dir", ".", "Output directory for generated files")
+	flag.Var((*fileModeFlag)(&cfg.FileMode), "file-mode", "Octal permissions for generated files in -output-dir, e.g. 0664 for a group-writable artifact store; caches keep 0644")
 	flag.StringVar(&cfg.CacheDir, "cache-dir", "./cache", "Cache directory for transcripts, GitHub guides, and intermediate LLM output")
 	flag.BoolVar(&cfg.Force, "force", false, "Ignore all caches; re-fetch and re-generate everything")
-	flag.BoolVar(&cfg.Force, "fetch-all", false, "Alias for --force")
-	flag.StringVar(&cfg.Only, "only", "", "Regenerate one output file only (e.g. labs-catalog.json)")
+	flag.Var((*boolAlias)(&cfg.Force), "fetch-all", "Alias for --force")
+	flag.BoolVar(&cfg.ForceCollect, "force-collect", false, "Ignore collection caches; re-fetch transcripts and guides but reuse generated output caches")
+	flag.BoolVar(&cfg.ForceGenerate, "force-generate", false, "Ignore generation caches; regenerate output from the cached corpus without re-downloading")
+	flag.BoolVar(&cfg.Offline, "offline", false, "Use only cached playlist info, transcripts and guides; fail on any cache miss instead of fetching")
+	flag.BoolVar(&cfg.Explain, "explain", false, "query only: think before answering and explain which intent_signals and personas matched")
+	flag.BoolVar(&cfg.NoLLM, "no-llm", false, "Build corpora and deterministic outputs only (index JSON, index table, corpus dumps); make no Claude calls")
+	flag.BoolVar(&cfg.Plan, "plan", false, "List each cache file the selected outputs and labs would use, whether it exists, and whether it would be reused or regenerated under the current flags, and stop; fetches nothing and makes no Claude calls")
+	flag.BoolVar(&cfg.CorpusStats, "corpus-stats", false, "Build corpora, print approximate per-lab token counts for transcript, guide and deck, and stop; makes no Claude calls")
+	flag.BoolVar(&cfg.DumpCorpus, "dump-corpus", false, "Write each lab's corpus, exactly as embedded in the catalog prompt, to <cache-dir>/corpus/<id>.md")
+	flag.StringVar(&cfg.SaveThinking, "save-thinking", "", "Directory to save Claude's extended-thinking output, one file per output and lab (e.g. why related_labs were chosen)")
+	flag.StringVar(&cfg.EventsFile, "events-file", "", "Append one JSON object per lifecycle event (phase started/finished, lab collected, generation started/cached/completed, error) to this file, for dashboards to tail")
+	flag.Var((*listFlag)(&cfg.Only), "only", "Regenerate only these output files, comma-separated (e.g. labs-catalog.json,recommender-system-prompt.md)")
 	flag.StringVar(&cfg.Lab, "lab", "", "Process only this lab ID (e.g. ll202509); implies --force for that lab")
-	flag.StringVar(&cfg.Model, "model", "claude-sonnet-4-6", "Claude model to use for generation")
+	flag.StringVar(&cfg.SinceLab, "since-lab", "", "Regenerate only labs with ID >= this one (e.g. ll202509); older caches are reused")
+	flag.IntVar(&cfg.Sample, "sample", 0, "Process only N randomly chosen labs, forced, for smoke-testing prompt changes; outputs go to <output-dir>/sample/ and the catalog there is marked as a partial sample")
+	flag.Int64Var(&cfg.Seed, "seed", 0, "Random seed for -sample, to repeat a selection (default: chosen from the clock and printed)")
+	flag.StringVar(&cfg.Model, "model", "claude-sonnet-4-6", "Model to use for generation, as named by -provider")
+	flag.StringVar(&cfg.Provider, "provider", "anthropic", `LLM backend: "anthropic" (ANTHROPIC_API_KEY) or "openai" for any OpenAI-compatible API such as OpenAI or Ollama (OPENAI_API_KEY, optional for local servers)`)
+	flag.StringVar(&cfg.ProviderURL, "provider-url", "", "API base URL for -provider openai (default "+claude.DefaultOpenAIURL+"; e.g. http://localhost:11434/v1 for Ollama)")
+	flag.BoolVar(&cfg.AllowUnknownModel, "allow-unknown-model", false, "Accept -model/-model-for names not in the built-in list (for new releases)")
+	flag.Var(modelForFlag(cfg.ModelFor), "model-for", "Per-output model override as file=model (repeatable), e.g. labs-catalog.json=claude-opus-4-6")
 	flag.StringVar(&cfg.YtDlpPath, "ytdlp-path", "yt-dlp", "Path to yt-dlp binary")
-	flag.StringVar(&cfg.DecksDir, "decks-dir", "../decks", "Directory containing PPTX slide decks")
+	flag.StringVar(&cfg.Proxy, "proxy", "", "Proxy URL for all outbound requests: GitHub guide, deck PDF and thumbnail fetches, the Claude/OpenAI and Voyage APIs, and yt-dlp as --proxy (default: HTTP_PROXY/HTTPS_PROXY, which yt-dlp honors too)")
+	flag.StringVar(&cfg.UserAgent, "user-agent", "llgen/1.0 (+https://github.com/mbarretta/doc-suggester)", "User-Agent header for GitHub guide fetches; include contact info")
+	flag.StringVar(&cfg.DecksDir, "decks-dir", "../decks", "Directory containing PPTX slide decks, relative to the working directory")
+	flag.BoolVar(&cfg.Thumbnails, "thumbnails", false, "Download each lab's YouTube thumbnail to <output>/thumbnails/ and show it in the learning-labs-index.md table")
+	flag.BoolVar(&cfg.PDFDecks, "pdf-decks", false, "For published labs without a local PPTX, download the public deck PDF and extract its text (requires pdftotext from poppler)")
+	flag.StringVar(&cfg.PdftotextPath, "pdftotext-path", "pdftotext", "Path to pdftotext binary, used with -pdf-decks")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 4, "Maximum number of labs built or generated in parallel")
+	flag.IntVar(&cfg.DownloadWorkers, "download-concurrency", 2, "Maximum number of transcript downloads (yt-dlp processes) in parallel; keep low to avoid YouTube throttling")
+	flag.StringVar(&cfg.CatalogSchemaFile, "catalog-schema", "", "File containing the catalog entry schema (default: built-in)")
+	flag.StringVar(&cfg.CatalogExampleFile, "catalog-example", "", "File containing the few-shot reference catalog entry (default: built-in ll202509)")
+	flag.StringVar(&cfg.CatalogFormat, "catalog-format", "json", `Catalog format: "json", or "ndjson" to also write labs-catalog.ndjson (a metadata line, then one lab per line); labs-catalog.json is always written, as later outputs read it`)
+	flag.StringVar(&cfg.PromptsDir, "prompts-dir", "", "Directory of text/template system prompts overriding the built-in ones: catalog-system.tmpl ({{.Schema}}, {{.Example}}), index-system.tmpl ({{.LabCount}}, {{.OldFormat}}, {{.NewFormat}}), recommender-system.tmpl ({{.WorkedExamples}}), compare-system.tmpl")
+	flag.BoolVar(&cfg.IncludeUnpublished, "include-unpublished", true, "Include labs recorded but not yet published; -include-unpublished=false leaves them out of every output (index, catalog, embeddings, recommender) for a published-only artifact set")
+	flag.StringVar(&cfg.CaveatsFile, "caveats-file", "", `Markdown caveats for the recommender: each "### <lab id> — ..." section replaces the built-in one for that lab, others are added`)
+	flag.IntVar(&cfg.ExcerptHead, "excerpt-head", 3000, "Transcript characters from the start included in catalog prompts")
+	flag.IntVar(&cfg.ExcerptTail, "excerpt-tail", 0, "Transcript characters from the end included in catalog prompts (keeps the wrap-up)")
+	flag.IntVar(&cfg.MaxInputTokens, "max-input-tokens", 100000, "Estimated input-token budget per catalog prompt; oversized corpora are truncated, transcript first (0 disables)")
+	flag.BoolVar(&cfg.Timecodes, "timecodes", false, "Send transcripts with [m:ss] timecodes and have Claude cite moments as https://youtu.be/{videoID}?t={seconds} links")
+	flag.BoolVar(&cfg.VideoDescription, "video-description", true, "Include each lab's cached YouTube description in catalog prompts as the source for instructor and date")
+	flag.IntVar(&cfg.MinTranscriptWords, "min-transcript-words", 200, "Drop and warn about transcripts shorter than this many words, e.g. a sign-in page saved as VTT (0 disables)")
+	flag.StringVar(&cfg.VTTDedup, "vtt-dedup", "auto", `Caption line dedup: "rolling" drops lines the next cue repeats (YouTube auto-captions), "none" keeps all (uploaded captions), "fuzzy" is rolling ignoring case and punctuation, "auto" picks rolling or none per file`)
+	flag.StringVar(&cfg.EmbedModel, "embed-model", "voyage-3.5", "Voyage AI model used for labs-embeddings.json")
+	flag.StringVar(&cfg.PricingFile, "pricing-file", "", "JSON file of model → {input_per_mtok, output_per_mtok} overriding built-in prices")
+	flag.IntVar(&cfg.WorkedExamples, "worked-examples", 3, "Number of worked examples in the recommender system prompt")
+	flag.BoolVar(&cfg.SkipPreflight, "skip-preflight", false, "Skip the startup check that the -provider API key (ANTHROPIC_API_KEY or OPENAI_API_KEY) is valid and can use the selected models")
+	flag.BoolVar(&cfg.Verbose, "v", false, "Verbose: also print full prompts and per-call token counts")
+	flag.BoolVar(&cfg.Quiet, "quiet", false, "Quiet: print only phase banners, warnings and results")
+	flag.IntVar(&cfg.ClaudeRPM, "claude-rpm", 0, "Cap generation requests per minute across all outputs and labs, shared by every client (0 = no cap); set below your API tier's limit to avoid 429s")
+	flag.DurationVar(&cfg.Timeout, "timeout", 0, "Abort the whole run after this duration (e.g. 45m); 0 means no limit")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "llgen — Chainguard Learning Labs generator\n\nUsage:\n")
+		fmt.Fprintf(os.Stderr, "  llgen [flags]                  generate all outputs\n")
+		fmt.Fprintf(os.Stderr, "  llgen query [flags] \"<text>\"   ask the generated recommender for a lab\n")
+		fmt.Fprintf(os.Stderr, "  llgen diff-catalog old new      changelog between two labs-catalog.json files\n")
+		fmt.Fprintf(os.Stderr, "  llgen check-videos [flags]      check every lab's video still resolves, without downloading\n")
+		fmt.Fprintf(os.Stderr, "  llgen compare [flags] a b       write compare-<a>-<b>.md, a generated side-by-side of two labs\n\nFlags:\n")
 		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nEnvironment:\n  ANTHROPIC_API_KEY  Required for all generation steps\n")
+		fmt.Fprintf(os.Stderr, "\nEnvironment:\n  ANTHROPIC_API_KEY  Required for all generation steps (not with -no-llm or -provider openai)\n  OPENAI_API_KEY     Bearer token for -provider openai (optional for local servers)\n  VOYAGE_API_KEY     Required for labs-embeddings.json (skipped in full runs when unset)\n")
+	}
+
+	args := os.Args[1:]
+	if len(args) > 0 && isSubcommand(args[0]) {
+		cfg.Command = args[0]
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args) // ExitOnError
+	cfg.Args = flag.Args()
+
+	if err := applyConfigFile(flag.CommandLine, cfg.ConfigFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.Verbose && cfg.Quiet {
+		fmt.Fprintln(os.Stderr, "-v and -quiet are mutually exclusive")
+		os.Exit(2)
+	}
+
+	if cfg.Explain && cfg.Command != "query" {
+		fmt.Fprintln(os.Stderr, "-explain can only be used with query")
+		os.Exit(2)
+	}
+
+	if cfg.CorpusStats && (cfg.NoLLM || cfg.Command != "") {
+		fmt.Fprintln(os.Stderr, "-corpus-stats cannot be combined with -no-llm or a subcommand")
+		os.Exit(2)
+	}
+
+	if cfg.Plan && (cfg.NoLLM || cfg.CorpusStats || cfg.Command != "") {
+		fmt.Fprintln(os.Stderr, "-plan cannot be combined with -no-llm, -corpus-stats or a subcommand")
+		os.Exit(2)
+	}
+
+	if cfg.NoLLM && cfg.Command != "" {
+		fmt.Fprintf(os.Stderr, "-no-llm cannot be used with %s\n", cfg.Command)
+		os.Exit(2)
+	}
+
+	if cfg.Offline && cfg.ForceCollect {
+		fmt.Fprintln(os.Stderr, "-offline and -force-collect are mutually exclusive")
+		os.Exit(2)
+	}
+
+	if cfg.Sample < 0 {
+		fmt.Fprintln(os.Stderr, "-sample must be positive")
+		os.Exit(2)
+	}
+	if cfg.Sample > 0 && (cfg.Lab != "" || cfg.SinceLab != "") {
+		fmt.Fprintln(os.Stderr, "-sample cannot be combined with -lab or -since-lab")
+		os.Exit(2)
 	}
 
-	flag.Parse()
+	if cfg.ExcerptHead < 0 || cfg.ExcerptTail < 0 {
+		fmt.Fprintln(os.Stderr, "-excerpt-head and -excerpt-tail must not be negative")
+		os.Exit(2)
+	}
+
+	if cfg.CatalogFormat != "json" && cfg.CatalogFormat != "ndjson" {
+		fmt.Fprintf(os.Stderr, "-catalog-format: unknown format %q (valid: json, ndjson)\n", cfg.CatalogFormat)
+		os.Exit(2)
+	}
+
+	switch cfg.VTTDedup {
+	case "auto", "rolling", "none", "fuzzy":
+	default:
+		fmt.Fprintf(os.Stderr, "-vtt-dedup: unknown strategy %q (valid: auto, rolling, none, fuzzy)\n", cfg.VTTDedup)
+		os.Exit(2)
+	}
+
+	for _, name := range cfg.Only {
+		if !isOutputFile(name) {
+			fmt.Fprintf(os.Stderr, "-only: unknown output %q (valid: %s)\n", name, strings.Join(OutputFiles, ", "))
+			os.Exit(2)
+		}
+	}
+
+	switch cfg.Provider {
+	case "anthropic":
+		if cfg.ProviderURL != "" {
+			fmt.Fprintln(os.Stderr, "-provider-url requires -provider openai")
+			os.Exit(2)
+		}
+	case "openai":
+		if cfg.ProviderURL == "" {
+			cfg.ProviderURL = claude.DefaultOpenAIURL
+		}
+		if strings.HasPrefix(cfg.Model, "claude-") {
+			fmt.Fprintln(os.Stderr, "-provider openai needs a -model the backend serves (e.g. gpt-4.1 or llama3.1)")
+			os.Exit(2)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "-provider: unknown backend %q (valid: anthropic, openai)\n", cfg.Provider)
+		os.Exit(2)
+	}
+
+	// Only Claude model names are known in advance.
+	allowUnknown := cfg.AllowUnknownModel || cfg.Provider != "anthropic"
+	for name, model := range cfg.ModelFor {
+		if !isOutputFile(name) {
+			fmt.Fprintf(os.Stderr, "-model-for: unknown output %q (valid: %s)\n", name, strings.Join(OutputFiles, ", "))
+			os.Exit(2)
+		}
+		checkModel("-model-for "+name, model, allowUnknown)
+	}
+	checkModel("-model", cfg.Model, allowUnknown)
+
+	// The default ../decks only works when run from llgen/; an absolute
+	// path makes the log and warnings say where decks were looked for.
+	if cfg.DecksDir != "" {
+		if abs, err := filepath.Abs(cfg.DecksDir); err == nil {
+			cfg.DecksDir = abs
+		}
+	}
 
 	// --lab implies --force for that lab (handled in main by clearing that lab's intermediates)
 	return cfg
@@ -52,6 +290,11 @@ func (c *Config) TranscriptDir() string {
 	return c.CacheDir
 }
 
+// DeckCacheDir returns the directory for downloaded public deck PDFs.
+func (c *Config) DeckCacheDir() string {
+	return c.CacheDir + "/decks"
+}
+
 // GitHubCacheDir returns the directory for cached GitHub guide markdown files.
 func (c *Config) GitHubCacheDir() string {
 	return c.CacheDir + "/github"
@@ -62,3 +305,206 @@ func (c *Config) CatalogCacheDir() string {
 	return c.CacheDir + "/catalog"
 }
 
+// ThumbnailsDir returns the directory for lab video thumbnails. It is under
+// the output directory because learning-labs-index.md links to them.
+func (c *Config) ThumbnailsDir() string {
+	return c.OutputDir + "/thumbnails"
+}
+
+// PlaylistCacheFile returns the cached yt-dlp playlist listing used by -offline.
+func (c *Config) PlaylistCacheFile() string {
+	return c.CacheDir + "/playlist.tsv"
+}
+
+// CorpusDumpDir returns the directory for per-lab corpus dumps.
+func (c *Config) CorpusDumpDir() string {
+	return c.CacheDir + "/corpus"
+}
+
+// EmbeddingsCacheDir returns the per-lab embedding vector cache directory.
+func (c *Config) EmbeddingsCacheDir() string {
+	return c.CacheDir + "/embeddings"
+}
+
+// ForceCollectLab reports whether collection caches (transcripts, guides)
+// for the given lab ID should be ignored, either because of -force or
+// -force-collect or because the lab was forced individually. Always false
+// under -offline, where the caches are the only source.
+func (c *Config) ForceCollectLab(id string) bool {
+	if c.Offline {
+		return false
+	}
+	return c.Force || c.ForceCollect || c.ForceLabs[id]
+}
+
+// ForceGenerateLab reports whether generation caches (catalog entries,
+// embeddings) for the given lab ID should be ignored.
+func (c *Config) ForceGenerateLab(id string) bool {
+	return c.ForceGeneration() || c.ForceLabs[id]
+}
+
+// UsesLLM reports whether the run makes generation calls, and so needs an
+// API key: the query and compare subcommands always do, and a generation
+// run does when -only selects an output the generator writes.
+func (c *Config) UsesLLM() bool {
+	if c.NoLLM || c.CorpusStats || c.Plan {
+		return false
+	}
+	if c.Command == "query" || c.Command == "compare" {
+		return true
+	}
+	for _, name := range OutputFiles {
+		if c.Selected(name) && UsesGenerator(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesGenerator reports whether the named output is written by the
+// generation model, rather than built from the lab data or, for the
+// embeddings, by the Voyage API.
+func UsesGenerator(name string) bool {
+	return name != "learning-labs-index.json" && name != "labs-embeddings.json"
+}
+
+// ForceGeneration reports whether whole-file generation caches, such as the
+// recommender's input hash, should be ignored.
+func (c *Config) ForceGeneration() bool {
+	return c.Force || c.ForceGenerate
+}
+
+// Selected reports whether the named output file should be generated:
+// true for every output when -only was not given.
+func (c *Config) Selected(name string) bool {
+	if len(c.Only) == 0 {
+		return true
+	}
+	for _, o := range c.Only {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelForOutput returns the model to use when generating the named output
+// file: the -model-for override if one was given, otherwise cfg.Model.
+func (c *Config) ModelForOutput(name string) string {
+	if m, ok := c.ModelFor[name]; ok {
+		return m
+	}
+	return c.Model
+}
+
+// checkModel exits if model is not a known Claude model, so a typo fails
+// at startup instead of as an API error on every lab.
+func checkModel(flagName, model string, allowUnknown bool) {
+	if allowUnknown || claude.IsKnownModel(model) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: unknown model %q; use -allow-unknown-model for new releases. Known models:\n  %s\n",
+		flagName, model, strings.Join(claude.KnownModels, "\n  "))
+	os.Exit(2)
+}
+
+func isSubcommand(name string) bool {
+	for _, s := range Subcommands {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isOutputFile(name string) bool {
+	for _, f := range OutputFiles {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// listFlag is a comma-separated string list. Repeating the flag appends.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// fileModeFlag is an octal file permission such as 0644 or 664.
+type fileModeFlag os.FileMode
+
+func (m *fileModeFlag) String() string {
+	if m == nil {
+		return "0644"
+	}
+	return fmt.Sprintf("%#o", uint32(*m))
+}
+
+func (m *fileModeFlag) Set(s string) error {
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0o777 {
+		return fmt.Errorf("want octal permission bits such as 0644, got %q", s)
+	}
+	*m = fileModeFlag(v)
+	return nil
+}
+
+// boolAlias is a boolean flag that writes through to another flag's variable.
+// Registering a second flag.BoolVar on the same pointer would reset it to
+// that call's default; an alias leaves the target's default untouched.
+type boolAlias bool
+
+func (b *boolAlias) String() string {
+	if b == nil {
+		return "false"
+	}
+	return strconv.FormatBool(bool(*b))
+}
+
+func (b *boolAlias) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = boolAlias(v)
+	return nil
+}
+
+// IsBoolFlag lets the alias be passed without a value, like a bool flag.
+func (b *boolAlias) IsBoolFlag() bool { return true }
+
+// modelForFlag collects repeated -model-for file=model values.
+type modelForFlag map[string]string
+
+func (m modelForFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m modelForFlag) Set(s string) error {
+	name, model, ok := strings.Cut(s, "=")
+	if !ok || name == "" || model == "" {
+		return fmt.Errorf("expected file=model, got %q", s)
+	}
+	m[name] = model
+	return nil
+}
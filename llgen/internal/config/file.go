@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultConfigFiles are looked up in the working directory when -config is
+// not given. The first one found is used.
+var defaultConfigFiles = []string{"llgen.yaml", "llgen.yml", "llgen.toml"}
+
+// flagAliases maps each alias flag to the flag it writes through to, so an
+// alias given on the command line also keeps the config file from setting
+// its target, and the reverse.
+var flagAliases = map[string]string{"fetch-all": "force"}
+
+// canonicalFlag returns the flag that name is an alias of, or name.
+func canonicalFlag(name string) string {
+	if target, ok := flagAliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// applyConfigFile sets every flag named in the config file that was not
+// given explicitly on the command line, so precedence is
+// flag > config file > default.
+//
+// Only flat settings are supported — one flag per key, as either YAML
+// ("key: value") or TOML ("key = value"). Keys are flag names; underscores
+// are accepted in place of dashes. Repeatable flags such as model-for take
+// a list: YAML "- item" lines under the key, or an inline [a, b] array.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	if path == "" {
+		for _, name := range defaultConfigFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	explicit := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { explicit[canonicalFlag(fl.Name)] = true })
+
+	set := func(key, value string, line int) error {
+		if fs.Lookup(key) == nil {
+			return fmt.Errorf("%s:%d: unknown setting %q", path, line, key)
+		}
+		if explicit[canonicalFlag(key)] {
+			return nil
+		}
+		if err := fs.Set(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, line, key, err)
+		}
+		return nil
+	}
+
+	var listKey string // key whose YAML "- item" lines follow
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" || line == "---" || strings.HasPrefix(line, "[") {
+			continue // blank, YAML document marker, or TOML table header
+		}
+
+		if strings.HasPrefix(line, "- ") && listKey != "" {
+			if err := set(listKey, unquote(strings.TrimSpace(line[2:])), n); err != nil {
+				return err
+			}
+			continue
+		}
+		listKey = ""
+
+		sep := strings.IndexAny(line, ":=")
+		if sep <= 0 {
+			return fmt.Errorf("%s:%d: expected key: value or key = value", path, n)
+		}
+		key := strings.ReplaceAll(strings.TrimSpace(line[:sep]), "_", "-")
+		value := strings.TrimSpace(line[sep+1:])
+
+		switch {
+		case value == "":
+			listKey = key
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					if err := set(key, item, n); err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			if err := set(key, unquote(value), n); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// stripComment removes a trailing "# ..." comment that is not inside quotes.
+func stripComment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
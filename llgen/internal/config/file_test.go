@@ -0,0 +1,178 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFlags is a small flag set covering each kind of value a config file
+// can set.
+type testFlags struct {
+	fs          *flag.FlagSet
+	outputDir   string
+	concurrency int
+	force       bool
+	only        []string
+}
+
+func newTestFlags() *testFlags {
+	f := &testFlags{fs: flag.NewFlagSet("llgen", flag.ContinueOnError)}
+	f.fs.StringVar(&f.outputDir, "output-dir", ".", "")
+	f.fs.IntVar(&f.concurrency, "concurrency", 4, "")
+	f.fs.BoolVar(&f.force, "force", false, "")
+	f.fs.Var((*boolAlias)(&f.force), "fetch-all", "")
+	f.fs.Var((*listFlag)(&f.only), "only", "")
+	return f
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		args        []string
+		outputDir   string
+		concurrency int
+		force       bool
+		only        []string
+		wantErr     string
+	}{
+		{
+			name:        "yaml scalars",
+			file:        "output-dir: out\nconcurrency: 8\nforce: true\n",
+			outputDir:   "out",
+			concurrency: 8,
+			force:       true,
+		},
+		{
+			name:        "toml scalars",
+			file:        "output_dir = \"out\"\nconcurrency = 8\n",
+			outputDir:   "out",
+			concurrency: 8,
+		},
+		{
+			name:        "yaml list",
+			file:        "only:\n  - labs-catalog.json\n  - \"learning-labs-index.md\"\nconcurrency: 2\n",
+			outputDir:   ".",
+			concurrency: 2,
+			only:        []string{"labs-catalog.json", "learning-labs-index.md"},
+		},
+		{
+			name:        "inline array",
+			file:        "only = [\"labs-catalog.json\", 'learning-labs-index.md']\n",
+			outputDir:   ".",
+			concurrency: 4,
+			only:        []string{"labs-catalog.json", "learning-labs-index.md"},
+		},
+		{
+			name:        "quoted value keeps #",
+			file:        "output-dir: \"out #1\" # trailing comment\n",
+			outputDir:   "out #1",
+			concurrency: 4,
+		},
+		{
+			name:        "comments, document marker and table header",
+			file:        "# llgen settings\n---\n[llgen]\nconcurrency: 3 # fewer workers\n\n",
+			outputDir:   ".",
+			concurrency: 3,
+		},
+		{
+			name:    "unknown key",
+			file:    "concurrency: 3\nmodle: claude-opus-4-6\n",
+			wantErr: `:2: unknown setting "modle"`,
+		},
+		{
+			name:    "bad value",
+			file:    "concurrency: many\n",
+			wantErr: ":1: concurrency:",
+		},
+		{
+			name:    "missing separator",
+			file:    "force\n",
+			wantErr: ":1: expected key: value",
+		},
+		{
+			name:        "explicit flags override the file",
+			file:        "output-dir: out\nconcurrency: 8\nonly: [labs-catalog.json]\n",
+			args:        []string{"-concurrency", "2", "-only", "recommender-system-prompt.md"},
+			outputDir:   "out",
+			concurrency: 2,
+			only:        []string{"recommender-system-prompt.md"},
+		},
+		{
+			name:        "explicit alias overrides its target in the file",
+			file:        "force: false\n",
+			args:        []string{"-fetch-all"},
+			outputDir:   ".",
+			concurrency: 4,
+			force:       true,
+		},
+		{
+			name:        "explicit target overrides its alias in the file",
+			file:        "fetch_all: true\n",
+			args:        []string{"-force=false"},
+			outputDir:   ".",
+			concurrency: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "llgen.yaml")
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f := newTestFlags()
+			if err := f.fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := applyConfigFile(f.fs, path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.outputDir != tt.outputDir || f.concurrency != tt.concurrency || f.force != tt.force {
+				t.Errorf("got output-dir %q, concurrency %d, force %v; want %q, %d, %v",
+					f.outputDir, f.concurrency, f.force, tt.outputDir, tt.concurrency, tt.force)
+			}
+			if !reflect.DeepEqual(f.only, tt.only) {
+				t.Errorf("only = %q, want %q", f.only, tt.only)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFileMissingDefaultIsIgnored(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := applyConfigFile(newTestFlags().fs, ""); err != nil {
+		t.Errorf("no default config file: err = %v, want nil", err)
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	for in, want := range map[string]string{
+		"concurrency: 3 # workers": "concurrency: 3 ",
+		"# whole line":             "",
+		`proxy: "http://h#x"`:      `proxy: "http://h#x"`,
+		`name: 'a # b' # c`:        `name: 'a # b' `,
+		"url: http://h/#frag":      "url: http://h/#frag",
+	} {
+		if got := stripComment(in); got != want {
+			t.Errorf("stripComment(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package embed produces vector embeddings for catalog text.
+//
+// Anthropic does not offer an embeddings endpoint; Voyage AI is the provider
+// Anthropic recommends, so it is the built-in implementation. Other providers
+// can be plugged in by implementing Embedder.
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Embedder converts texts into vectors, one per input, in input order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+	Model() string
+}
+
+const voyageURL = "https://api.voyageai.com/v1/embeddings"
+
+// VoyageClient calls the Voyage AI embeddings API.
+type VoyageClient struct {
+	apiKey string
+	model  string
+	http   *http.Client
+}
+
+// NewVoyageClient creates a Voyage embedder for the given API key and model,
+// sending requests through transport (nil for the default).
+func NewVoyageClient(apiKey, model string, transport http.RoundTripper) *VoyageClient {
+	return &VoyageClient{apiKey: apiKey, model: model, http: &http.Client{Transport: transport}}
+}
+
+// Model returns the embedding model name.
+func (c *VoyageClient) Model() string { return c.model }
+
+// Embed embeds texts as documents in a single request.
+func (c *VoyageClient) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	body, err := json.Marshal(map[string]any{
+		"input":      texts,
+		"model":      c.model,
+		"input_type": "document",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, voyageURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embed.Voyage: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("embed.Voyage: read body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embed.Voyage: status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("embed.Voyage: decode: %w", err)
+	}
+
+	out := make([][]float64, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embed.Voyage: response index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	for i, v := range out {
+		if v == nil {
+			return nil, fmt.Errorf("embed.Voyage: no embedding returned for input %d", i)
+		}
+	}
+	return out, nil
+}
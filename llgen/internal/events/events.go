@@ -0,0 +1,99 @@
+// Package events writes the -events-file stream: one JSON object per line for
+// each lifecycle event of a run (phases, collected labs, generation calls,
+// errors), for dashboards that tail it. It is independent of the logging
+// level. With no file open, every call is a no-op.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event kinds.
+const (
+	PhaseStarted        = "phase_started"
+	PhaseFinished       = "phase_finished"
+	LabCollected        = "lab_collected"
+	GenerationStarted   = "generation_started"
+	GenerationCached    = "generation_cached"
+	GenerationCompleted = "generation_completed"
+	Error               = "error"
+)
+
+// Event is one line of the stream. Fields other than Time and Kind are set
+// only when they apply.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"event"`
+	Phase  string    `json:"phase,omitempty"`
+	Output string    `json:"output,omitempty"` // output file, for generation events
+	Lab    string    `json:"lab,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+var (
+	mu  sync.Mutex
+	out *os.File
+)
+
+// Open starts appending events to path, creating it if needed.
+func Open(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open events file: %w", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	out = f
+	return nil
+}
+
+// Close stops the stream and closes the file.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return nil
+	}
+	err := out.Close()
+	out = nil
+	return err
+}
+
+// Emit timestamps e and appends it to the stream. Write errors are dropped:
+// a dashboard feed must never fail the run.
+func Emit(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	out.Write(append(line, '\n'))
+}
+
+// Phase emits a phase_started event and returns a func that emits the
+// matching phase_finished.
+func Phase(name string) func() {
+	Emit(Event{Kind: PhaseStarted, Phase: name})
+	return func() { Emit(Event{Kind: PhaseFinished, Phase: name}) }
+}
+
+// Generation emits a generation event of kind for output and lab ("" for
+// whole-file outputs).
+func Generation(kind, output, lab string) {
+	Emit(Event{Kind: kind, Output: output, Lab: lab})
+}
+
+// Fail emits an error event; lab may be "".
+func Fail(lab string, err error) {
+	Emit(Event{Kind: Error, Lab: lab, Error: err.Error()})
+}
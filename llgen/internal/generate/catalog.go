@@ -1,20 +1,30 @@
 package generate
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"llgen/data"
+	"llgen/internal/atomicfile"
 	"llgen/internal/claude"
 	"llgen/internal/config"
+	"llgen/internal/events"
+	"llgen/internal/logging"
+	"llgen/internal/pool"
+	"llgen/internal/progress"
+	"llgen/internal/prompts"
 	"llgen/internal/transform"
 )
 
 // catalogSchema describes the JSON fields expected in each lab entry.
+// Overridable with -catalog-schema.
 const catalogSchema = `{
   "id": "string — lab ID e.g. ll202509",
   "id_note": "string or null — notes about inferred/unpublished IDs",
@@ -40,6 +50,7 @@ const catalogSchema = `{
 }`
 
 // referenceEntry is a concrete example (ll202509) shown to Claude for few-shot guidance.
+// Overridable with -catalog-example.
 const referenceEntry = `{
   "id": "ll202509",
   "id_note": null,
@@ -73,103 +84,295 @@ const referenceEntry = `{
 }`
 
 // Catalog generates labs-catalog.json using per-lab LLM calls with caching.
-func Catalog(ctx context.Context, client *claude.Client, cfg *config.Config, labs []data.LabMeta, corpora map[string]*transform.LabCorpus) error {
+// Labs are processed through a worker pool bounded by cfg.Concurrency.
+func Catalog(ctx context.Context, client claude.Generator, cfg *config.Config, labs []data.LabMeta, corpora map[string]*transform.LabCorpus) error {
 	if err := os.MkdirAll(cfg.CatalogCacheDir(), 0o755); err != nil {
 		return fmt.Errorf("mkdir catalog cache: %w", err)
 	}
 
-	var entries []json.RawMessage
+	schema, err := loadOverride(cfg.CatalogSchemaFile, catalogSchema)
+	if err != nil {
+		return fmt.Errorf("catalog schema: %w", err)
+	}
+	example, err := loadOverride(cfg.CatalogExampleFile, referenceEntry)
+	if err != nil {
+		return fmt.Errorf("catalog example: %w", err)
+	}
+	if !json.Valid([]byte(example)) {
+		return fmt.Errorf("catalog example %s is not valid JSON", cfg.CatalogExampleFile)
+	}
+	system, err := catalogSystemPrompt(cfg, schema, example)
+	if err != nil {
+		return err
+	}
+
+	// Labs are generated concurrently; results are index-addressed so the
+	// assembled catalog keeps data.Labs order.
+	entries := make([]json.RawMessage, len(labs))
+	errs := make([]error, len(labs))
 
-	for _, lab := range labs {
+	bar := progress.New("catalog", len(labs))
+	genEntry := func(i int) {
+		lab := labs[i]
 		cacheFile := filepath.Join(cfg.CatalogCacheDir(), lab.ID+".json")
 
 		// Use cache unless forced
-		if !cfg.Force {
+		if !cfg.ForceGenerateLab(lab.ID) {
 			if cached, err := os.ReadFile(cacheFile); err == nil {
-				if json.Valid(cached) {
-					entries = append(entries, json.RawMessage(cached))
-					fmt.Printf("  catalog: %s (cached)\n", lab.ID)
-					continue
+				if canon, err := canonicalJSON(cached); err == nil {
+					entries[i] = canon
+					events.Generation(events.GenerationCached, "labs-catalog.json", lab.ID)
+					bar.Step(lab.ID + " (cached)")
+					return
 				}
 			}
 		}
 
-		fmt.Printf("  catalog: generating %s...\n", lab.ID)
 		corpus := corpora[lab.ID]
-		entry, err := generateCatalogEntry(ctx, client, lab, corpus)
+		ctx := claude.WithLabel(ctx, "labs-catalog.json", lab.ID)
+		events.Generation(events.GenerationStarted, "labs-catalog.json", lab.ID)
+		entry, err := generateCatalogEntry(ctx, client, cfg, lab, corpus, system)
 		if err != nil {
-			return fmt.Errorf("catalog entry %s: %w", lab.ID, err)
+			errs[i] = fmt.Errorf("catalog entry %s: %w", lab.ID, err)
+			return
+		}
+		canon, err := canonicalJSON([]byte(entry))
+		if err != nil {
+			errs[i] = fmt.Errorf("catalog entry %s: %w", lab.ID, err)
+			return
 		}
 
 		// Write to cache
-		if err := os.WriteFile(cacheFile, []byte(entry), 0o644); err != nil {
-			return fmt.Errorf("write catalog cache %s: %w", cacheFile, err)
+		if err := atomicfile.WriteFile(cacheFile, canon, 0o644); err != nil {
+			errs[i] = fmt.Errorf("write catalog cache %s: %w", cacheFile, err)
+			return
+		}
+		entries[i] = canon
+		events.Generation(events.GenerationCompleted, "labs-catalog.json", lab.ID)
+		bar.Step(lab.ID)
+	}
+	poolErr := pool.ForEach(ctx, cfg.Concurrency, len(labs), genEntry)
+	bar.Done()
+
+	// A lab that failed even after the client's own retries is usually
+	// hitting a transient overload; give each one more try once the rest
+	// are done rather than let it sink the file.
+	var failed []int
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) > 0 && ctx.Err() == nil {
+		ids := make([]string, len(failed))
+		for j, i := range failed {
+			ids[j] = labs[i].ID
+			errs[i] = nil
+		}
+		logging.Infof("  catalog: retrying %d failed labs: %s\n", len(failed), strings.Join(ids, ", "))
+		bar = progress.New("catalog retry", len(failed))
+		pool.ForEach(ctx, cfg.Concurrency, len(failed), func(j int) { genEntry(failed[j]) })
+		bar.Done()
+	}
+
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			events.Fail(labs[i].ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr == nil && poolErr != nil {
+		firstErr = fmt.Errorf("catalog: %w", poolErr)
+	}
+
+	outPath := filepath.Join(cfg.OutputDir, "labs-catalog.json")
+
+	// On failure, still assemble the entries that did complete, so the run
+	// leaves a usable catalog and the list of labs to retry. Their caches
+	// are kept, so a re-run without -force regenerates only the failed ones.
+	if firstErr != nil {
+		var done []json.RawMessage
+		var failed []string
+		for i, entry := range entries {
+			if entry == nil {
+				failed = append(failed, labs[i].ID)
+			} else {
+				done = append(done, entry)
+			}
+		}
+		if len(done) == 0 || cfg.Lab != "" {
+			return firstErr
+		}
+		catalog := catalogFile{
+			Description: catalogDescription(cfg),
+			Note:        partialNote(failed, len(labs)),
+			FailedLabs:  failed,
+			Labs:        done,
+		}
+		if err := writeCatalog(cfg, outPath, catalog); err != nil {
+			return fmt.Errorf("%w (partial catalog not written: %v)", firstErr, err)
+		}
+		return fmt.Errorf("%d of %d catalog entries still failing after a retry pass (%s); partial catalog written to %s: %w", len(failed), len(labs), strings.Join(failed, ", "), outPath, firstErr)
+	}
+
+	// A single -lab run splices its entry into the existing catalog instead
+	// of rebuilding from every lab's cache.
+	if cfg.Lab != "" && len(entries) == 1 {
+		spliced, err := spliceCatalogEntry(cfg, outPath, cfg.Lab, entries[0])
+		if err != nil {
+			return err
+		}
+		if spliced {
+			return nil
 		}
-		entries = append(entries, json.RawMessage(entry))
 	}
 
-	// Assemble final JSON
-	catalog := struct {
-		Description string            `json:"description"`
-		Labs        []json.RawMessage `json:"labs"`
-	}{
-		Description: "Chainguard Learning Labs catalog. 22 labs total across two eras. New-format labs (ll202505+) have a written lab guide, PDF deck, and GitHub demo repo. Old-format labs (pre-ll202505) are video-only.",
-		Labs:        entries,
+	catalog := catalogFile{Description: catalogDescription(cfg), Labs: entries}
+	if cfg.Sample > 0 {
+		catalog.Note = fmt.Sprintf("PARTIAL SAMPLE: %d of %d labs (-sample %d -seed %d); not the full catalog.", len(entries), len(data.Labs), cfg.Sample, cfg.Seed)
+	}
+	return writeCatalog(cfg, outPath, catalog)
+}
+
+// partialNote is the note of a catalog missing the failed labs out of total.
+func partialNote(failed []string, total int) string {
+	return fmt.Sprintf("PARTIAL: %d of %d labs failed to generate (%s); re-run without -force to retry them.", len(failed), total, strings.Join(failed, ", "))
+}
+
+// catalogDescription is the catalog's description field. The lab count is
+// the lab map's, which -include-unpublished=false has already filtered.
+func catalogDescription(cfg *config.Config) string {
+	desc := fmt.Sprintf("Chainguard Learning Labs catalog. %d labs total across two eras. New-format labs (ll202505+) have a written lab guide, PDF deck, and GitHub demo repo. Old-format labs (pre-ll202505) are video-only.", len(data.Labs))
+	if !cfg.IncludeUnpublished {
+		desc += " Published labs only; labs recorded but not yet published are left out."
 	}
+	return desc
+}
+
+// catalogFile is the on-disk shape of labs-catalog.json.
+type catalogFile struct {
+	Description string            `json:"description"`
+	Note        string            `json:"note,omitempty"`        // set for -sample runs and partial catalogs
+	FailedLabs  []string          `json:"failed_labs,omitempty"` // labs missing from a partial catalog
+	Labs        []json.RawMessage `json:"labs"`
+}
 
-	out, err := json.MarshalIndent(catalog, "", "  ")
+// writeCatalog writes catalog to outPath and, with -catalog-format ndjson,
+// the same catalog as newline-delimited JSON next to it (see
+// writeCatalogNDJSON).
+func writeCatalog(cfg *config.Config, outPath string, catalog catalogFile) error {
+	out, err := marshalIndent(catalog)
 	if err != nil {
 		return fmt.Errorf("marshal catalog: %w", err)
 	}
 
-	outPath := filepath.Join(cfg.OutputDir, "labs-catalog.json")
-	if err := os.WriteFile(outPath, out, 0o644); err != nil {
+	if err := atomicfile.WriteFile(outPath, out, cfg.FileMode); err != nil {
 		return fmt.Errorf("write %s: %w", outPath, err)
 	}
 	fmt.Printf("  wrote %s\n", outPath)
+	if cfg.CatalogFormat == "ndjson" {
+		return writeCatalogNDJSON(cfg, strings.TrimSuffix(outPath, ".json")+".ndjson", catalog)
+	}
 	return nil
 }
 
-func generateCatalogEntry(ctx context.Context, client *claude.Client, lab data.LabMeta, corpus *transform.LabCorpus) (string, error) {
-	system := fmt.Sprintf(`You are building a structured catalog of the Chainguard Learning Labs series.
-
-For the lab described below, output ONLY a valid JSON object matching this schema:
-%s
+// catalogMeta is the first line of labs-catalog.ndjson; every later line is
+// one lab entry.
+type catalogMeta struct {
+	Description string   `json:"description"`
+	Note        string   `json:"note,omitempty"`
+	FailedLabs  []string `json:"failed_labs,omitempty"`
+	LabCount    int      `json:"lab_count"`
+}
 
-Rules:
-- Output raw JSON only. No markdown fences. No prose. No array wrapper.
-- Use null (not "") for unavailable string fields.
-- Use [] for empty arrays.
-- The "recording_url" is always https://www.youtube.com/watch?v={videoID}
-- Set "lab_page_url" to https://edu.chainguard.dev/software-security/learning-labs/{id}/ for new-format published labs; null otherwise.
-- Set "deck_public_url" to https://edu.chainguard.dev/downloads/learning-lab-YYYYMM.pdf for new-format published labs that have a deck; null otherwise.
-- "intent_signals" should contain 8-15 specific search queries that would indicate a user wants this lab.
-- "related_labs" should list 2-4 IDs of the most topically similar labs from the series.
+// writeCatalogNDJSON writes catalog to outPath as a metadata line followed
+// by one compact lab entry per line, for streaming consumers.
+func writeCatalogNDJSON(cfg *config.Config, outPath string, catalog catalogFile) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(catalogMeta{Description: catalog.Description, Note: catalog.Note, FailedLabs: catalog.FailedLabs, LabCount: len(catalog.Labs)}); err != nil {
+		return fmt.Errorf("marshal catalog metadata: %w", err)
+	}
+	for _, entry := range catalog.Labs {
+		if err := json.Compact(&buf, entry); err != nil {
+			return fmt.Errorf("compact catalog entry %s: %w", entryID(entry), err)
+		}
+		buf.WriteByte('\n')
+	}
+	if err := atomicfile.WriteFile(outPath, buf.Bytes(), cfg.FileMode); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("  wrote %s\n", outPath)
+	return nil
+}
 
-Here is a complete reference example for ll202509:
-%s`, catalogSchema, referenceEntry)
+// spliceCatalogEntry replaces (or inserts, in data.Labs order) the entry for
+// labID in an existing catalog file, leaving every other entry untouched.
+// Returns false without error when there is no existing catalog to splice into.
+func spliceCatalogEntry(cfg *config.Config, outPath, labID string, entry json.RawMessage) (bool, error) {
+	existing, err := os.ReadFile(outPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read %s: %w", outPath, err)
+	}
+	var catalog catalogFile
+	if err := json.Unmarshal(existing, &catalog); err != nil {
+		return false, fmt.Errorf("parse existing %s: %w", outPath, err)
+	}
 
-	var inputParts []string
-	inputParts = append(inputParts, fmt.Sprintf("## Lab: %s\n", lab.ID))
-	inputParts = append(inputParts, fmt.Sprintf("- VideoID: %s\n- Era: %s\n- Status: %s\n- IDInferred: %v\n- DeckFile: %s\n- GitHubID: %s\n",
-		lab.VideoID, lab.Era, lab.Status, lab.IDInferred, lab.DeckFile, lab.GitHubID))
+	order := make(map[string]int, len(data.Labs))
+	for i, l := range data.Labs {
+		order[l.ID] = i
+	}
 
-	if corpus != nil {
-		if corpus.Title != "" {
-			inputParts = append(inputParts, fmt.Sprintf("- Title (from playlist): %s\n", corpus.Title))
+	insertAt := len(catalog.Labs)
+	replaced := false
+	for i, raw := range catalog.Labs {
+		id := entryID(raw)
+		if id == labID {
+			catalog.Labs[i] = entry
+			replaced = true
+			break
 		}
-		if corpus.Transcript != "" {
-			inputParts = append(inputParts, fmt.Sprintf("\n### Transcript (first 3000 chars):\n%s\n", corpus.TranscriptExcerpt(3000)))
+		if pos, ok := order[id]; ok && pos > order[labID] && i < insertAt {
+			insertAt = i
 		}
-		if corpus.GitHubGuide != "" {
-			inputParts = append(inputParts, fmt.Sprintf("\n### GitHub Lab Guide:\n%s\n", corpus.GitHubGuide))
-		}
-		if corpus.DeckText != "" {
-			inputParts = append(inputParts, fmt.Sprintf("\n### Slide Deck Text:\n%s\n", corpus.DeckText))
+	}
+	if !replaced {
+		catalog.Labs = append(catalog.Labs[:insertAt], append([]json.RawMessage{entry}, catalog.Labs[insertAt:]...)...)
+	}
+	// A partial catalog no longer misses this lab; once it misses none it
+	// is complete.
+	if i := slices.Index(catalog.FailedLabs, labID); i >= 0 {
+		catalog.FailedLabs = slices.Delete(catalog.FailedLabs, i, i+1)
+		if len(catalog.FailedLabs) == 0 {
+			catalog.FailedLabs, catalog.Note = nil, ""
+		} else {
+			catalog.Note = partialNote(catalog.FailedLabs, len(catalog.Labs)+len(catalog.FailedLabs))
 		}
 	}
 
-	user := strings.Join(inputParts, "")
+	logging.Infof("  catalog: spliced %s into existing %s\n", labID, outPath)
+	return true, writeCatalog(cfg, outPath, catalog)
+}
+
+// entryID extracts the "id" field from a raw catalog entry.
+func entryID(raw json.RawMessage) string {
+	var e struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(raw, &e)
+	return e.ID
+}
+
+func generateCatalogEntry(ctx context.Context, client claude.Generator, cfg *config.Config, lab data.LabMeta, corpus *transform.LabCorpus, system string) (string, error) {
+	user := catalogUserPrompt(cfg, lab, corpus, len(system), true)
 
 	// Use extended thinking for better cross-lab reasoning
 	var text string
@@ -177,6 +380,7 @@ Here is a complete reference example for ll202509:
 	text, err = client.GenerateWithThinking(ctx, system, user, 2048, 4000)
 	if err != nil {
 		// Fall back to standard generation if extended thinking fails
+		claude.RecordFallback(ctx)
 		text, err = client.Generate(ctx, system, user, 2048)
 		if err != nil {
 			return "", err
@@ -190,6 +394,7 @@ Here is a complete reference example for ll202509:
 	var raw json.RawMessage
 	if err := json.Unmarshal([]byte(text), &raw); err != nil {
 		// Retry once without extended thinking
+		claude.RecordFallback(ctx)
 		text2, err2 := client.Generate(ctx, system, user+" OUTPUT JSON ONLY. NO FENCES.", 2048)
 		if err2 != nil {
 			return "", fmt.Errorf("invalid JSON and retry failed: original=%v retry=%v", err, err2)
@@ -198,24 +403,219 @@ Here is a complete reference example for ll202509:
 		if err3 := json.Unmarshal([]byte(text2), &raw); err3 != nil {
 			return "", fmt.Errorf("invalid JSON after retry: %v\nraw: %s", err3, text2[:min(200, len(text2))])
 		}
-		return text2, nil
+		text = text2
+	}
+
+	return setLabURLs(text, lab)
+}
+
+// setLabURLs overwrites the URL fields of a generated entry with the values
+// derived from lab metadata, so a misremembered link from Claude never
+// reaches the catalog.
+func setLabURLs(text string, lab data.LabMeta) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var entry map[string]any
+	if err := dec.Decode(&entry); err != nil {
+		return "", fmt.Errorf("catalog entry is not a JSON object: %w", err)
+	}
+	nullable := func(url string) any {
+		if url == "" {
+			return nil
+		}
+		return url
+	}
+	entry["recording_url"] = lab.RecordingURL()
+	entry["lab_page_url"] = nullable(lab.LabPageURL())
+	entry["deck_public_url"] = nullable(lab.DeckURL())
+
+	out, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("marshal catalog entry: %w", err)
+	}
+	return string(out), nil
+}
+
+// catalogSystemPrompt renders the catalog system prompt (see prompts) with
+// the entry schema and few-shot example.
+func catalogSystemPrompt(cfg *config.Config, schema, example string) (string, error) {
+	return prompts.Render(cfg.PromptsDir, prompts.CatalogSystem, struct{ Schema, Example string }{schema, example})
+}
+
+// catalogUserPrompt renders the lab's metadata and corpus as sent to Claude,
+// truncated to the -max-input-tokens budget left after systemLen characters
+// of system prompt. logTrim reports any truncation.
+func catalogUserPrompt(cfg *config.Config, lab data.LabMeta, corpus *transform.LabCorpus, systemLen int, logTrim bool) string {
+	var inputParts []string
+	inputParts = append(inputParts, fmt.Sprintf("## Lab: %s\n", lab.ID))
+	inputParts = append(inputParts, fmt.Sprintf("- VideoID: %s\n- Era: %s\n- Status: %s\n- IDInferred: %v\n- DeckFile: %s\n- GitHubID: %s\n",
+		lab.VideoID, lab.Era, lab.Status, lab.IDInferred, lab.DeckFile, lab.GitHubID))
+	inputParts = append(inputParts, fmt.Sprintf("- Recording URL: %s\n- Lab page URL: %s\n- Deck URL: %s\n- GitHub URL: %s\n",
+		orDash(lab.RecordingURL()), orDash(lab.LabPageURL()), orDash(lab.DeckURL()), orDash(lab.GitHubURL())))
+
+	if corpus != nil {
+		// A title that fell back to the lab ID tells Claude nothing.
+		if corpus.Title != "" && corpus.TitleSource != "lab ID" {
+			source := corpus.TitleSource
+			if source == "" {
+				source = "playlist"
+			}
+			inputParts = append(inputParts, fmt.Sprintf("- Title (from %s): %s\n", source, corpus.Title))
+		}
+		inputParts = append(inputParts, fmt.Sprintf("- Sources loaded: %s (leave fields you cannot support from these empty rather than guessing)\n", corpus.SourceSummary()))
+		if corpus.Description != "" {
+			inputParts = append(inputParts, fmt.Sprintf("\n### Video Description (use this first for \"instructor\" and \"date\"; presenters are usually named here):\n%s\n", corpus.Description))
+		}
+		transcript := corpus.TranscriptExcerpt(cfg.ExcerptHead, cfg.ExcerptTail)
+		transcriptHeading := "### Transcript (excerpt):"
+		if cfg.Timecodes && len(corpus.Segments) > 0 {
+			transcript = corpus.TimecodedExcerpt(cfg.ExcerptHead, cfg.ExcerptTail)
+			transcriptHeading = fmt.Sprintf("### Transcript (excerpt, [m:ss] timecodes; when a field refers to a specific moment, cite it as %s with t in seconds):",
+				strings.Replace(lab.RecordingURLAt(0), "t=0", "t={seconds}", 1))
+		}
+		guide, deck := corpus.GitHubGuide, corpus.DeckText
+		if cfg.MaxInputTokens > 0 {
+			fixed := systemLen + len(strings.Join(inputParts, ""))
+			budget := cfg.MaxInputTokens*charsPerToken - fixed
+			if trimmed := fitBudget(budget, &transcript, &guide, &deck); trimmed > 0 && logTrim {
+				logging.Infof("  catalog: %s input over %d-token budget; truncated %d chars\n", lab.ID, cfg.MaxInputTokens, trimmed)
+			}
+		}
+		if transcript != "" {
+			inputParts = append(inputParts, fmt.Sprintf("\n%s\n%s\n", transcriptHeading, transcript))
+		}
+		if guide != "" {
+			inputParts = append(inputParts, fmt.Sprintf("\n### GitHub Lab Guide:\n%s\n", guide))
+		}
+		if deck != "" && len(corpus.DeckShared) > 0 {
+			// A reused template deck is mostly boilerplate; say so rather
+			// than let it pull every lab that shares it toward one entry.
+			inputParts = append(inputParts, fmt.Sprintf("\n### Slide Deck Text (template deck shared with %s; treat it as generic background and base the entry on the transcript and guide, using only slides specific to this lab):\n%s\n",
+				strings.Join(corpus.DeckShared, ", "), deck))
+		} else if deck != "" {
+			inputParts = append(inputParts, fmt.Sprintf("\n### Slide Deck Text:\n%s\n", deck))
+		}
+	}
+
+	return strings.Join(inputParts, "")
+}
+
+// canonicalJSON re-encodes a JSON document with sorted object keys and no
+// insignificant whitespace. Claude's key order and spacing vary between runs;
+// canonicalizing makes re-runs over unchanged content byte-identical.
+func canonicalJSON(raw []byte) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // keep numbers exactly as written
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil { // maps encode with sorted keys
+		return nil, err
+	}
+	return json.RawMessage(bytes.TrimSpace(buf.Bytes())), nil
+}
+
+// marshalIndent is json.MarshalIndent without HTML escaping, so "<" and "&"
+// in prose fields stay readable in the committed file.
+func marshalIndent(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	return text, nil
+// loadOverride returns the contents of path, or def when path is empty.
+func loadOverride(path, def string) (string, error) {
+	if path == "" {
+		return def, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
 }
 
+// stripFences removes a Markdown code fence wrapped around a response. It
+// works on whole lines at the document boundary only: the opening fence is
+// the first line, and the closing one the first later line that is nothing
+// but "```". JSON strings can't hold raw newlines, so fences inside string
+// values (escaped, as in "```bash\nmake\n```") are never matched, and
+// a response that is already valid JSON is returned untouched.
 func stripFences(s string) string {
 	s = strings.TrimSpace(s)
-	if strings.HasPrefix(s, "```") {
-		lines := strings.SplitN(s, "\n", 2)
-		if len(lines) == 2 {
-			s = lines[1]
+	if json.Valid([]byte(s)) || !strings.HasPrefix(s, "```") {
+		return s
+	}
+	_, body, ok := strings.Cut(s, "\n")
+	if !ok {
+		return s
+	}
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "```" {
+			return strings.TrimSpace(strings.Join(lines[:i], "\n"))
 		}
 	}
-	if strings.HasSuffix(s, "```") {
-		s = s[:strings.LastIndex(s, "```")]
+	// A closing fence glued to the last line, as in "}```".
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+}
+
+// charsPerToken is a conservative estimate for English prose and Markdown,
+// used to convert -max-input-tokens into a character budget.
+const charsPerToken = 4
+
+// truncationMarker is appended to any section cut by fitBudget.
+const truncationMarker = "\n[... truncated to fit input budget]"
+
+// fitBudget shortens sections, in order, until their combined length fits
+// within budget characters, so the first section (the transcript) gives way
+// before the guide and deck. It returns the number of characters removed.
+func fitBudget(budget int, sections ...*string) int {
+	total := 0
+	for _, s := range sections {
+		total += len(*s)
+	}
+	over := total - max(budget, 0)
+	removed := 0
+	for _, s := range sections {
+		if over <= 0 {
+			break
+		}
+		if *s == "" {
+			continue
+		}
+		keep := len(*s) - over - len(truncationMarker)
+		if keep <= 0 {
+			removed += len(*s)
+			over -= len(*s)
+			*s = ""
+			continue
+		}
+		// Back up to a word boundary so the cut never splits a word, or,
+		// in text without one, to a rune boundary so it never splits a
+		// multi-byte character.
+		if i := strings.LastIndexAny((*s)[:keep], " \t\n"); i > 0 {
+			keep = i
+		} else {
+			for keep > 0 && !utf8.RuneStart((*s)[keep]) {
+				keep--
+			}
+		}
+		removed += len(*s) - keep
+		over -= len(*s) - keep - len(truncationMarker)
+		*s = (*s)[:keep] + truncationMarker
 	}
-	return strings.TrimSpace(s)
+	return removed
 }
 
 func min(a, b int) int {
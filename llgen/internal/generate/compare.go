@@ -0,0 +1,45 @@
+package generate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"llgen/internal/atomicfile"
+	"llgen/internal/claude"
+	"llgen/internal/config"
+	"llgen/internal/prompts"
+	"llgen/internal/transform"
+)
+
+// Compare generates compare-<a>-<b>.md, a side-by-side of two labs for
+// deciding which to recommend. Each lab's input is rendered as for its
+// catalog entry, with the -max-input-tokens budget split between the two.
+func Compare(ctx context.Context, client claude.Generator, cfg *config.Config, a, b *transform.LabCorpus) error {
+	system, err := prompts.Render(cfg.PromptsDir, prompts.CompareSystem, nil)
+	if err != nil {
+		return err
+	}
+
+	half := *cfg
+	half.MaxInputTokens = cfg.MaxInputTokens / 2
+	user := strings.Join([]string{
+		catalogUserPrompt(&half, a.Lab, a, len(system)/2, true),
+		catalogUserPrompt(&half, b.Lab, b, len(system)/2, true),
+		fmt.Sprintf("Now compare %s and %s.", a.Lab.ID, b.Lab.ID),
+	}, "\n\n")
+
+	ctx = claude.WithLabel(ctx, "compare", "")
+	text, err := client.Generate(ctx, system, user, 4096)
+	if err != nil {
+		return fmt.Errorf("compare %s %s: %w", a.Lab.ID, b.Lab.ID, err)
+	}
+
+	outPath := filepath.Join(cfg.OutputDir, fmt.Sprintf("compare-%s-%s.md", a.Lab.ID, b.Lab.ID))
+	if err := atomicfile.WriteFile(outPath, []byte(strings.TrimSpace(text)+"\n"), cfg.FileMode); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("  wrote %s\n", outPath)
+	return nil
+}
@@ -0,0 +1,51 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"llgen/data"
+	"llgen/internal/atomicfile"
+	"llgen/internal/config"
+	"llgen/internal/transform"
+)
+
+// DumpCorpora writes each lab's corpus to <cacheDir>/corpus/<id>.md exactly
+// as it is embedded in the catalog prompt — excerpted and truncated by the
+// same settings — so a bad entry can be checked against its real input.
+func DumpCorpora(cfg *config.Config, labs []data.LabMeta, corpora map[string]*transform.LabCorpus) error {
+	schema, err := loadOverride(cfg.CatalogSchemaFile, catalogSchema)
+	if err != nil {
+		return fmt.Errorf("catalog schema: %w", err)
+	}
+	example, err := loadOverride(cfg.CatalogExampleFile, referenceEntry)
+	if err != nil {
+		return fmt.Errorf("catalog example: %w", err)
+	}
+	system, err := catalogSystemPrompt(cfg, schema, example)
+	if err != nil {
+		return err
+	}
+	systemLen := len(system)
+
+	if err := os.MkdirAll(cfg.CorpusDumpDir(), 0o755); err != nil {
+		return fmt.Errorf("mkdir corpus dump dir: %w", err)
+	}
+	for _, lab := range labs {
+		corpus := corpora[lab.ID]
+		var warnings string
+		if corpus != nil {
+			for _, w := range corpus.Warnings {
+				warnings += "<!-- warning: " + w + " -->\n"
+			}
+		}
+		text := warnings + catalogUserPrompt(cfg, lab, corpus, systemLen, false)
+		path := filepath.Join(cfg.CorpusDumpDir(), lab.ID+".md")
+		if err := atomicfile.WriteFile(path, []byte(text), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	fmt.Printf("  wrote %d corpus dumps to %s\n", len(labs), cfg.CorpusDumpDir())
+	return nil
+}
@@ -0,0 +1,117 @@
+package generate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"llgen/internal/atomicfile"
+	"llgen/internal/config"
+	"llgen/internal/embed"
+	"llgen/internal/events"
+	"llgen/internal/logging"
+)
+
+// embeddingCache is the per-lab cache record. The model and source text are
+// stored alongside the vector so a model switch or catalog edit invalidates it.
+type embeddingCache struct {
+	Model  string    `json:"model"`
+	Text   string    `json:"text"`
+	Vector []float64 `json:"vector"`
+}
+
+// Embeddings generates labs-embeddings.json from labs-catalog.json, mapping
+// each lab ID to a vector of its summary, intent signals, and outcome.
+// Vectors are cached per lab; only uncached labs are sent to the embedder.
+func Embeddings(ctx context.Context, embedder embed.Embedder, cfg *config.Config) error {
+	catalogPath := filepath.Join(cfg.OutputDir, "labs-catalog.json")
+	catalogBytes, err := os.ReadFile(catalogPath)
+	if err != nil {
+		return fmt.Errorf("read labs-catalog.json (run catalog generation first): %w", err)
+	}
+	var catalog struct {
+		Labs []struct {
+			ID            string   `json:"id"`
+			Summary       string   `json:"summary"`
+			IntentSignals []string `json:"intent_signals"`
+			WhatYouBuild  string   `json:"what_you_build"`
+		} `json:"labs"`
+	}
+	if err := json.Unmarshal(catalogBytes, &catalog); err != nil {
+		return fmt.Errorf("parse labs-catalog.json: %w", err)
+	}
+
+	if err := os.MkdirAll(cfg.EmbeddingsCacheDir(), 0o755); err != nil {
+		return fmt.Errorf("mkdir embeddings cache: %w", err)
+	}
+
+	vectors := make(map[string][]float64, len(catalog.Labs))
+	var pendingIDs, pendingTexts []string
+
+	for _, lab := range catalog.Labs {
+		text := strings.Join([]string{
+			lab.Summary,
+			strings.Join(lab.IntentSignals, "; "),
+			lab.WhatYouBuild,
+		}, "\n")
+
+		cacheFile := filepath.Join(cfg.EmbeddingsCacheDir(), lab.ID+".json")
+		if !cfg.ForceGenerateLab(lab.ID) {
+			if cached, err := os.ReadFile(cacheFile); err == nil {
+				var c embeddingCache
+				if json.Unmarshal(cached, &c) == nil && c.Model == embedder.Model() && c.Text == text {
+					vectors[lab.ID] = c.Vector
+					logging.Infof("  embeddings: %s (cached)\n", lab.ID)
+					events.Generation(events.GenerationCached, "labs-embeddings.json", lab.ID)
+					continue
+				}
+			}
+		}
+		pendingIDs = append(pendingIDs, lab.ID)
+		pendingTexts = append(pendingTexts, text)
+	}
+
+	if len(pendingTexts) > 0 {
+		logging.Infof("  embeddings: embedding %d labs with %s...\n", len(pendingTexts), embedder.Model())
+		for _, id := range pendingIDs {
+			events.Generation(events.GenerationStarted, "labs-embeddings.json", id)
+		}
+		embedded, err := embedder.Embed(ctx, pendingTexts)
+		if err != nil {
+			return fmt.Errorf("embed: %w", err)
+		}
+		for i, id := range pendingIDs {
+			vectors[id] = embedded[i]
+			events.Generation(events.GenerationCompleted, "labs-embeddings.json", id)
+			rec, err := json.Marshal(embeddingCache{Model: embedder.Model(), Text: pendingTexts[i], Vector: embedded[i]})
+			if err != nil {
+				return fmt.Errorf("marshal embedding cache %s: %w", id, err)
+			}
+			cacheFile := filepath.Join(cfg.EmbeddingsCacheDir(), id+".json")
+			if err := atomicfile.WriteFile(cacheFile, rec, 0o644); err != nil {
+				return fmt.Errorf("write embedding cache %s: %w", cacheFile, err)
+			}
+		}
+	}
+
+	out, err := json.Marshal(struct {
+		Model string               `json:"model"`
+		Labs  map[string][]float64 `json:"labs"`
+	}{
+		Model: embedder.Model(),
+		Labs:  vectors,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal embeddings: %w", err)
+	}
+
+	outPath := filepath.Join(cfg.OutputDir, "labs-embeddings.json")
+	if err := atomicfile.WriteFile(outPath, out, cfg.FileMode); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("  wrote %s\n", outPath)
+	return nil
+}
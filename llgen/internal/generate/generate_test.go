@@ -0,0 +1,531 @@
+package generate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"llgen/data"
+	"llgen/internal/collect"
+	"llgen/internal/config"
+	"llgen/internal/transform"
+)
+
+// mockClient is a claude.Generator that returns canned responses instead of
+// calling the API. respond picks the response for each request; when nil,
+// text is returned for every call.
+type mockClient struct {
+	text        string
+	thinkingErr error
+	respond     func(system, user string) (string, error)
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *mockClient) Model() string { return "mock-model" }
+
+func (m *mockClient) Generate(ctx context.Context, system, user string, maxTokens int64) (string, error) {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+	if m.respond != nil {
+		return m.respond(system, user)
+	}
+	return m.text, nil
+}
+
+func (m *mockClient) GenerateWithThinking(ctx context.Context, system, user string, maxTokens int64, budgetTokens int64) (string, error) {
+	if m.thinkingErr != nil {
+		m.mu.Lock()
+		m.calls++
+		m.mu.Unlock()
+		return "", m.thinkingErr
+	}
+	return m.Generate(ctx, system, user, maxTokens)
+}
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	return &config.Config{
+		OutputDir:      filepath.Join(dir, "out"),
+		CacheDir:       filepath.Join(dir, "cache"),
+		FileMode:       0o644,
+		Concurrency:    2,
+		ExcerptHead:    3000,
+		WorkedExamples: 3,
+	}
+}
+
+func mkdirOutput(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalogOffline(t *testing.T) {
+	cfg := testConfig(t)
+	mkdirOutput(t, cfg)
+	labs := data.Labs[:3]
+
+	// Claude's URLs are wrong on purpose; the catalog must use LabMeta's.
+	client := &mockClient{
+		thinkingErr: errors.New("thinking unavailable"),
+		respond: func(system, user string) (string, error) {
+			id := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(user, "## Lab: "), "\n", 2)[0])
+			return "```json\n" + `{"id": "` + id + `", "recording_url": "https://example.com", "lab_page_url": "https://example.com"}` + "\n```", nil
+		},
+	}
+
+	if err := Catalog(context.Background(), client, cfg, labs, nil); err != nil {
+		t.Fatalf("Catalog: %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(cfg.OutputDir, "labs-catalog.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var catalog struct {
+		Labs []struct {
+			ID           string  `json:"id"`
+			RecordingURL string  `json:"recording_url"`
+			LabPageURL   *string `json:"lab_page_url"`
+		} `json:"labs"`
+	}
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		t.Fatalf("parse catalog: %v", err)
+	}
+	if len(catalog.Labs) != len(labs) {
+		t.Fatalf("got %d entries, want %d", len(catalog.Labs), len(labs))
+	}
+	for i, got := range catalog.Labs {
+		lab := labs[i]
+		if got.ID != lab.ID {
+			t.Errorf("entry %d: id = %q, want %q", i, got.ID, lab.ID)
+		}
+		if got.RecordingURL != lab.RecordingURL() {
+			t.Errorf("%s: recording_url = %q, want %q", lab.ID, got.RecordingURL, lab.RecordingURL())
+		}
+		if want := lab.LabPageURL(); (got.LabPageURL == nil) != (want == "") || (got.LabPageURL != nil && *got.LabPageURL != want) {
+			t.Errorf("%s: lab_page_url = %v, want %q", lab.ID, got.LabPageURL, want)
+		}
+	}
+
+	// A second run is served entirely from the per-lab cache.
+	client.calls = 0
+	if err := Catalog(context.Background(), client, cfg, labs, nil); err != nil {
+		t.Fatalf("Catalog (cached): %v", err)
+	}
+	if client.calls != 0 {
+		t.Errorf("cached run made %d calls, want 0", client.calls)
+	}
+	// -catalog-format ndjson adds a metadata line and one entry per line.
+	cfg.CatalogFormat = "ndjson"
+	if err := Catalog(context.Background(), client, cfg, labs, nil); err != nil {
+		t.Fatalf("Catalog (ndjson): %v", err)
+	}
+	nd, err := os.ReadFile(filepath.Join(cfg.OutputDir, "labs-catalog.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(nd), "\n"), "\n")
+	if len(lines) != len(labs)+1 {
+		t.Fatalf("ndjson has %d lines, want metadata + %d entries", len(lines), len(labs))
+	}
+	var meta struct {
+		LabCount int `json:"lab_count"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &meta); err != nil || meta.LabCount != len(labs) {
+		t.Errorf("metadata line %q: lab_count = %d, err %v", lines[0], meta.LabCount, err)
+	}
+	for i, line := range lines[1:] {
+		if id := entryID(json.RawMessage(line)); id != labs[i].ID {
+			t.Errorf("ndjson line %d: id = %q, want %q", i+1, id, labs[i].ID)
+		}
+	}
+}
+
+func TestIndexOffline(t *testing.T) {
+	cfg := testConfig(t)
+	mkdirOutput(t, cfg)
+	client := &mockClient{text: "# Chainguard Learning Labs\n\nIntro.\n\n## Two Eras\n\nProse.\n"}
+	info := map[string]collect.VideoInfo{
+		data.Labs[1].VideoID: {Title: "Safer Runtimes", UploadDate: "20251210"},
+	}
+
+	if err := Index(context.Background(), client, cfg, data.Labs, info); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(cfg.OutputDir, "learning-labs-index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(b)
+	if !strings.HasPrefix(doc, "# Chainguard Learning Labs") {
+		t.Errorf("index does not start with Claude's prose:\n%s", doc)
+	}
+	for _, lab := range data.Labs {
+		if !strings.Contains(doc, "| "+lab.ID) {
+			t.Errorf("index table missing %s", lab.ID)
+		}
+	}
+	if !strings.Contains(doc, "| Safer Runtimes | 2025-12-10 |") {
+		t.Errorf("index table missing title/date from playlist info")
+	}
+
+	// -thumbnails adds a leading column, with a dash where none was saved.
+	cfg.Thumbnails = true
+	if err := os.MkdirAll(cfg.ThumbnailsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.ThumbnailsDir(), data.Labs[0].ID+".jpg"), []byte("jpg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Index(context.Background(), client, cfg, data.Labs, info); err != nil {
+		t.Fatalf("Index (thumbnails): %v", err)
+	}
+	if b, err = os.ReadFile(filepath.Join(cfg.OutputDir, "learning-labs-index.md")); err != nil {
+		t.Fatal(err)
+	}
+	doc = string(b)
+	if !strings.Contains(doc, "| ![](thumbnails/"+data.Labs[0].ID+".jpg) | "+data.Labs[0].ID) || !strings.Contains(doc, "| — | "+data.Labs[1].ID+" |") {
+		t.Errorf("index table missing thumbnail column:\n%s", doc)
+	}
+}
+
+func TestRecommenderSkipsUnchangedInputs(t *testing.T) {
+	cfg := testConfig(t)
+	mkdirOutput(t, cfg)
+	if err := os.MkdirAll(cfg.CacheDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	catalog := `{"labs": [{"id": "ll202509", "intent_signals": ["static images"]}]}`
+	if err := os.WriteFile(filepath.Join(cfg.OutputDir, "labs-catalog.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &mockClient{text: "You are a lab recommender."}
+
+	for run := 0; run < 2; run++ {
+		if err := Recommender(context.Background(), client, cfg); err != nil {
+			t.Fatalf("Recommender run %d: %v", run, err)
+		}
+	}
+	if client.calls != 1 {
+		t.Errorf("made %d calls over two identical runs, want 1", client.calls)
+	}
+
+	// -file-mode applies to the output but not to the cached input hash.
+	cfg.FileMode = 0o664
+	cfg.ForceGenerate = true
+	if err := Recommender(context.Background(), client, cfg); err != nil {
+		t.Fatalf("Recommender (file mode): %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(cfg.OutputDir, "recommender-system-prompt.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o664 {
+		t.Errorf("output mode = %v, want -rw-rw-r--", fi.Mode().Perm())
+	}
+	if fi, err = os.Stat(filepath.Join(cfg.CacheDir, "recommender.sha256")); err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o644 {
+		t.Errorf("cache mode = %v, want -rw-r--r--", fi.Mode().Perm())
+	}
+}
+
+func TestFitBudgetTrimsTranscriptFirst(t *testing.T) {
+	transcript := strings.Repeat("word ", 200) // 1000 chars
+	guide := strings.Repeat("step ", 100)      // 500 chars
+	deck := "slides"
+
+	removed := fitBudget(700, &transcript, &guide, &deck)
+	if got := len(transcript) + len(guide) + len(deck); got > 700 {
+		t.Errorf("sections total %d chars, want <= 700", got)
+	}
+	if removed == 0 || !strings.HasSuffix(transcript, truncationMarker) {
+		t.Errorf("transcript not truncated: removed=%d", removed)
+	}
+	if guide != strings.Repeat("step ", 100) || deck != "slides" {
+		t.Errorf("guide or deck changed although trimming the transcript sufficed")
+	}
+
+	if removed := fitBudget(10000, &transcript, &guide, &deck); removed != 0 {
+		t.Errorf("removed %d chars from input already within budget", removed)
+	}
+}
+
+func TestFitBudgetKeepsUTF8Valid(t *testing.T) {
+	// No whitespace, so the cut falls back to a rune boundary; the range of
+	// budgets lands it on every byte of a three-byte character.
+	for budget := 500; budget < 506; budget++ {
+		transcript := strings.Repeat("漢字", 300)
+		fitBudget(budget, &transcript)
+		if !utf8.ValidString(transcript) {
+			t.Errorf("budget %d: truncated transcript is not valid UTF-8", budget)
+		}
+		if len(transcript) > budget || !strings.HasSuffix(transcript, truncationMarker) {
+			t.Errorf("budget %d: got %d bytes, marker %v", budget, len(transcript), strings.HasSuffix(transcript, truncationMarker))
+		}
+	}
+}
+
+func TestMergeCaveatsOverridesAndAppends(t *testing.T) {
+	extra := "## Team notes\n\n### ll202510\n- **FIXED**: The auth token step works again.\n\n### ll202602 — New Lab\n- Recorded, not yet published.\n"
+	got := mergeCaveats(hardcodedCaveats, extra)
+
+	if strings.Contains(got, "auth token step for Chainguard Libraries for JavaScript is broken") {
+		t.Error("ll202510 section was not replaced")
+	}
+	if !strings.Contains(got, "### ll202510\n- **FIXED**") {
+		t.Error("replacement ll202510 section missing")
+	}
+	if !strings.Contains(got, "### ll202602 — New Lab") {
+		t.Error("new ll202602 section was not appended")
+	}
+	if !strings.Contains(got, "### ll202509 — Static Chainguard Container Images") {
+		t.Error("untouched built-in section was dropped")
+	}
+	if strings.Contains(got, "## Team notes") {
+		t.Error("extra file's ## heading should not be carried over")
+	}
+	if strings.Index(got, "ll202510") > strings.Index(got, "ll202509") {
+		t.Error("replaced section moved out of its built-in position")
+	}
+}
+
+func TestDropUnknownLabCaveats(t *testing.T) {
+	all := data.Labs
+	t.Cleanup(func() { data.Labs = all })
+	data.Labs = slices.DeleteFunc(slices.Clone(all), func(l data.LabMeta) bool { return !l.Published() })
+
+	got := dropUnknownLabCaveats(hardcodedCaveats)
+	if strings.Contains(got, "ll202601") || strings.Contains(got, "hkoj-dm-5z8") {
+		t.Errorf("unpublished ll202601 caveat kept:\n%s", got)
+	}
+	for _, want := range []string{"## Known Issues", "### ll202509 — Static", "### Series notes"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestStripFencesLeavesFencesInsideStrings(t *testing.T) {
+	entry := `{"id": "ll202509", "what_you_build": "A hardened image built with:\n` + "```bash\\nmake image\\n```" + `"}`
+	for name, response := range map[string]string{
+		"bare":            entry,
+		"fenced":          "```json\n" + entry + "\n```",
+		"fenced+trailing": "```json\n" + entry + "\n```\nLet me know if you need changes.",
+		"glued close":     "```json\n" + entry + "```",
+	} {
+		got := stripFences(response)
+		var parsed struct {
+			WhatYouBuild string `json:"what_you_build"`
+		}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Errorf("%s: stripFences result is not JSON: %v\n%s", name, err, got)
+			continue
+		}
+		if want := "A hardened image built with:\n```bash\nmake image\n```"; parsed.WhatYouBuild != want {
+			t.Errorf("%s: what_you_build = %q, want %q", name, parsed.WhatYouBuild, want)
+		}
+	}
+}
+
+func TestPlanCatalogActions(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Only = []string{"labs-catalog.json"}
+	labs := data.Labs[:3]
+	if err := os.MkdirAll(cfg.CatalogCacheDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range map[string]string{labs[0].ID: `{"id": "x"}`, labs[1].ID: `not json`} {
+		if err := os.WriteFile(filepath.Join(cfg.CatalogCacheDir(), file+".json"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	catalogActions := func() []string {
+		var got []string
+		for _, it := range Plan(cfg, labs) {
+			if it.Output == "labs-catalog.json" {
+				got = append(got, it.Action)
+			}
+		}
+		return got
+	}
+	if got, want := strings.Join(catalogActions(), ","), "reuse,regenerate,generate"; got != want {
+		t.Errorf("actions = %s, want %s", got, want)
+	}
+	cfg.ForceGenerate = true
+	if got, want := strings.Join(catalogActions(), ","), "regenerate,regenerate,generate"; got != want {
+		t.Errorf("-force-generate actions = %s, want %s", got, want)
+	}
+}
+
+func TestCatalogWritesPartialOnFailure(t *testing.T) {
+	cfg := testConfig(t)
+	mkdirOutput(t, cfg)
+	labs := data.Labs[:3]
+	failing := labs[1].ID
+
+	client := &mockClient{
+		respond: func(system, user string) (string, error) {
+			id := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(user, "## Lab: "), "\n", 2)[0])
+			if id == failing {
+				return "", errors.New("overloaded")
+			}
+			return `{"id": "` + id + `"}`, nil
+		},
+	}
+
+	err := Catalog(context.Background(), client, cfg, labs, nil)
+	if err == nil || !strings.Contains(err.Error(), failing) {
+		t.Fatalf("Catalog error = %v, want one naming %s", err, failing)
+	}
+
+	b, err := os.ReadFile(filepath.Join(cfg.OutputDir, "labs-catalog.json"))
+	if err != nil {
+		t.Fatalf("partial catalog not written: %v", err)
+	}
+	var catalog catalogFile
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Labs) != 2 || len(catalog.FailedLabs) != 1 || catalog.FailedLabs[0] != failing {
+		t.Errorf("partial catalog has %d labs, failed_labs %v; want 2 and [%s]", len(catalog.Labs), catalog.FailedLabs, failing)
+	}
+	if !strings.HasPrefix(catalog.Note, "PARTIAL") {
+		t.Errorf("note = %q, want a PARTIAL note", catalog.Note)
+	}
+
+	// Regenerating the failed lab with -lab completes the catalog.
+	failing = ""
+	cfg.Lab = labs[1].ID
+	if err := Catalog(context.Background(), client, cfg, labs[1:2], nil); err != nil {
+		t.Fatalf("Catalog -lab: %v", err)
+	}
+	if b, err = os.ReadFile(filepath.Join(cfg.OutputDir, "labs-catalog.json")); err != nil {
+		t.Fatal(err)
+	}
+	catalog = catalogFile{}
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Labs) != 3 || catalog.FailedLabs != nil || catalog.Note != "" {
+		t.Errorf("after -lab: %d labs, failed_labs %v, note %q; want 3, none and no note", len(catalog.Labs), catalog.FailedLabs, catalog.Note)
+	}
+}
+
+func TestCompareSendsBothLabs(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxInputTokens = 1000
+	mkdirOutput(t, cfg)
+	a := &transform.LabCorpus{Lab: data.Labs[0], Transcript: strings.Repeat("alpha ", 2000)}
+	b := &transform.LabCorpus{Lab: data.Labs[1], GitHubGuide: "# Beta guide"}
+
+	var gotUser string
+	client := &mockClient{respond: func(system, user string) (string, error) {
+		gotUser = user
+		return "# compared\n", nil
+	}}
+	if err := Compare(context.Background(), client, cfg, a, b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Lab: " + a.Lab.ID, "## Lab: " + b.Lab.ID, "# Beta guide", truncationMarker} {
+		if !strings.Contains(gotUser, want) {
+			t.Errorf("user prompt missing %q", want)
+		}
+	}
+	out, err := os.ReadFile(filepath.Join(cfg.OutputDir, "compare-"+a.Lab.ID+"-"+b.Lab.ID+".md"))
+	if err != nil || string(out) != "# compared\n" {
+		t.Errorf("compare file = %q, %v", out, err)
+	}
+}
+
+func TestWriteManifestKeepsUnwrittenOutputs(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Model = "test-model"
+	mkdirOutput(t, cfg)
+	labs := data.Labs[:2]
+	client := &mockClient{respond: func(system, user string) (string, error) {
+		id := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(user, "## Lab: "), "\n", 2)[0])
+		return `{"id": "` + id + `"}`, nil
+	}}
+	if err := Catalog(context.Background(), client, cfg, labs, nil); err != nil {
+		t.Fatal(err)
+	}
+	corpora := map[string]*transform.LabCorpus{labs[0].ID: {Lab: labs[0], Transcript: "hello"}}
+	if err := WriteManifest(cfg, []string{"labs-catalog.json"}, corpora); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.OutputDir, "learning-labs-index.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A later run that writes only the index keeps the catalog's entry.
+	if err := WriteManifest(cfg, []string{"learning-labs-index.json"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(cfg.OutputDir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Outputs) != 2 || m.Outputs[0].File != "learning-labs-index.json" || m.Outputs[1].File != "labs-catalog.json" {
+		t.Fatalf("outputs = %+v, want index json then catalog", m.Outputs)
+	}
+	cat := m.Outputs[1]
+	if cat.Model != "test-model" || len(cat.SHA256) != 64 || len(cat.Labs) != 2 {
+		t.Errorf("catalog entry = %+v", cat)
+	}
+	if cat.Labs[0].CorpusSHA256 != corpora[labs[0].ID].Hash() || cat.Labs[1].CorpusSHA256 != "" {
+		t.Errorf("catalog labs = %+v, want the first lab's corpus hash only", cat.Labs)
+	}
+	if idx := m.Outputs[0]; idx.Model != "" || len(idx.Labs) != len(data.Labs) || idx.Labs[0].CorpusSHA256 != corpora[labs[0].ID].Hash() {
+		t.Errorf("index entry = %+v, want no model, every lab, and the earlier corpus hash kept", idx)
+	}
+}
+
+func TestCatalogRetriesFailedLabsOnce(t *testing.T) {
+	cfg := testConfig(t)
+	mkdirOutput(t, cfg)
+	labs := data.Labs[:3]
+	flaky := labs[0].ID
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	client := &mockClient{
+		thinkingErr: errors.New("thinking unavailable"),
+		respond: func(system, user string) (string, error) {
+			id := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(user, "## Lab: "), "\n", 2)[0])
+			mu.Lock()
+			attempts[id]++
+			n := attempts[id]
+			mu.Unlock()
+			if id == flaky && n == 1 {
+				return "", errors.New("overloaded")
+			}
+			return `{"id": "` + id + `"}`, nil
+		},
+	}
+	if err := Catalog(context.Background(), client, cfg, labs, nil); err != nil {
+		t.Fatalf("Catalog: %v (flaky lab should succeed on the retry pass)", err)
+	}
+	if attempts[flaky] != 2 {
+		t.Errorf("flaky lab generated %d times, want 2", attempts[flaky])
+	}
+}
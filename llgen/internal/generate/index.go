@@ -8,73 +8,209 @@ import (
 	"strings"
 
 	"llgen/data"
+	"llgen/internal/atomicfile"
 	"llgen/internal/claude"
 	"llgen/internal/collect"
 	"llgen/internal/config"
+	"llgen/internal/events"
+	"llgen/internal/prompts"
 )
 
 // Index generates learning-labs-index.md from lab metadata + playlist info.
-// No transcripts or LLM synthesis needed for the index — it is assembled from
-// structured metadata, with Claude writing the narrative header and notes.
-func Index(ctx context.Context, client *claude.Client, cfg *config.Config, labs []data.LabMeta, playlistInfo map[string]collect.VideoInfo) error {
+// The summary table is assembled in Go from structured metadata so every lab
+// and link is present; Claude only writes the narrative intro and "Two Eras"
+// section above it.
+func Index(ctx context.Context, client claude.Generator, cfg *config.Config, labs []data.LabMeta, playlistInfo map[string]collect.VideoInfo) error {
 	// Build a structured description of all labs to pass as input
 	var roster strings.Builder
 	roster.WriteString("Chainguard Learning Labs — complete lab roster (newest first):\n\n")
-	roster.WriteString("| ID | Video ID | Era | Status | DeckFile | GitHubID |\n")
-	roster.WriteString("|---|---|---|---|---|---|\n")
+	roster.WriteString("| ID | Title | Era | Status |\n")
+	roster.WriteString("|---|---|---|---|\n")
+	var newFormat, oldFormat int
 	for _, lab := range labs {
-		title := ""
-		if info, ok := playlistInfo[lab.VideoID]; ok {
-			title = info.Title
+		if lab.Era == "new-format" {
+			newFormat++
+		} else {
+			oldFormat++
 		}
-		inferred := ""
+		roster.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
+			lab.ID, playlistInfo[lab.VideoID].Title, lab.Era, lab.Status,
+		))
+	}
+
+	system, err := prompts.Render(cfg.PromptsDir, prompts.IndexSystem, struct{ LabCount, OldFormat, NewFormat int }{len(labs), oldFormat, newFormat})
+	if err != nil {
+		return err
+	}
+
+	user := roster.String()
+
+	ctx = claude.WithLabel(ctx, "learning-labs-index.md", "")
+	events.Generation(events.GenerationStarted, "learning-labs-index.md", "")
+	text, err := client.Generate(ctx, system, user, 2048)
+	if err != nil {
+		return fmt.Errorf("generate index: %w", err)
+	}
+	events.Generation(events.GenerationCompleted, "learning-labs-index.md", "")
+
+	var doc strings.Builder
+	doc.WriteString(strings.TrimSpace(text))
+	doc.WriteString("\n\n")
+	thumbDir := ""
+	if cfg.Thumbnails {
+		thumbDir = cfg.ThumbnailsDir()
+	}
+	doc.WriteString(indexTable(labs, playlistInfo, thumbDir))
+
+	outPath := filepath.Join(cfg.OutputDir, "learning-labs-index.md")
+	if err := atomicfile.WriteFile(outPath, []byte(doc.String()), cfg.FileMode); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("  wrote %s\n", outPath)
+	return nil
+}
+
+// IndexTable writes the data-driven index table on its own to
+// <cacheDir>/index-table.md. Used by -no-llm, which cannot produce the
+// prose half of learning-labs-index.md.
+func IndexTable(cfg *config.Config, labs []data.LabMeta, playlistInfo map[string]collect.VideoInfo) error {
+	outPath := filepath.Join(cfg.CacheDir, "index-table.md")
+	if err := atomicfile.WriteFile(outPath, []byte(indexTable(labs, playlistInfo, "")), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("  wrote %s\n", outPath)
+	return nil
+}
+
+// indexTable renders the "All Labs" summary table, newest first, followed by
+// a note for any lab that is recorded but not yet published. With thumbDir
+// set, a leading column shows each lab's thumbnail from that directory,
+// linked relative to the output directory; labs without one get a dash.
+func indexTable(labs []data.LabMeta, playlistInfo map[string]collect.VideoInfo, thumbDir string) string {
+	var sb strings.Builder
+	sb.WriteString("## All Labs\n\n")
+	if thumbDir != "" {
+		sb.WriteString("| | ")
+	} else {
+		sb.WriteString("| ")
+	}
+	sb.WriteString("ID | Title | Date | Era | Status | Video | Guide | Deck | Repo |\n")
+	if thumbDir != "" {
+		sb.WriteString("|---")
+	}
+	sb.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+
+	var unpublished []string
+	inferred := false
+	for _, lab := range labs {
+		info := playlistInfo[lab.VideoID]
+		id := lab.ID
 		if lab.IDInferred {
-			inferred = " (inferred)"
+			id += " (inferred)"
+			inferred = true
 		}
-		deck := lab.DeckFile
-		if deck == "" {
-			deck = "—"
+		if thumbDir != "" {
+			thumb := "—"
+			if _, err := os.Stat(filepath.Join(thumbDir, lab.ID+".jpg")); err == nil {
+				thumb = "![](" + filepath.Base(thumbDir) + "/" + lab.ID + ".jpg)"
+			}
+			fmt.Fprintf(&sb, "| %s ", thumb)
 		}
-		github := lab.GitHubID
-		if github == "" {
-			github = "—"
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			id,
+			orDash(strings.ReplaceAll(info.Title, "|", "\\|")),
+			orDash(formatUploadDate(info.UploadDate)),
+			lab.Era,
+			lab.Status,
+			mdLink("Video", lab.RecordingURL()),
+			mdLink("Guide", lab.LabPageURL()),
+			mdLink("Deck", lab.DeckURL()),
+			mdLink("Repo", lab.GitHubURL()),
+		)
+		if lab.Status != "published" {
+			unpublished = append(unpublished, lab.ID)
 		}
-		roster.WriteString(fmt.Sprintf("| %s%s | %s | %s | %s | %s | %s |\n",
-			lab.ID, inferred, lab.VideoID, lab.Era, lab.Status, deck, github,
-		))
-		if title != "" {
-			roster.WriteString(fmt.Sprintf("|   | Title: %s | | | | |\n", title))
+	}
+
+	if len(unpublished) > 0 {
+		sb.WriteString("\n")
+		for _, id := range unpublished {
+			fmt.Fprintf(&sb, "> **Note:** %s is recorded but not yet published.\n", id)
 		}
 	}
+	if inferred {
+		sb.WriteString("\n*ID cells marked \"(inferred)\" indicate the ID was inferred from the upload date.*\n")
+	}
+	return sb.String()
+}
 
-	system := `You are a technical writer producing documentation for the Chainguard Learning Labs series.
-Generate a well-structured index markdown document for all 22 labs.
+func mdLink(text, url string) string {
+	if url == "" {
+		return "—"
+	}
+	return "[" + text + "](" + url + ")"
+}
 
-The document must include:
-1. A brief introduction explaining what Chainguard Learning Labs are
-2. A "Two Eras" section explaining old-format (video-only, 14 labs) vs new-format (structured guide + PDF + GitHub, 8+ labs)
-3. A summary table with ALL 22 labs (newest first) — columns: ID | Title | Date | Era | Status | Video | Guide | Deck | Repo
-4. Per-lab links where available using these URL patterns:
-   - Video: https://www.youtube.com/watch?v={videoID}
-   - Lab guide: https://edu.chainguard.dev/software-security/learning-labs/{id}/ (new-format only)
-   - Slide deck: https://edu.chainguard.dev/downloads/learning-lab-{YYYYMM}.pdf (new-format published only)
-   - GitHub: https://github.com/chainguard-dev/edu/tree/main/content/software-security/learning-labs/{id} (when GitHubID is set)
-5. A note that ll202601 is recorded but not yet published
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
 
-Use "—" for unavailable links. ID cells marked "(inferred)" indicate the ID was inferred from the upload date.
-Output only the markdown document, no preamble.`
+// indexEntry is one row of learning-labs-index.json.
+type indexEntry struct {
+	ID         string `json:"id"`
+	IDInferred bool   `json:"id_inferred"`
+	Title      string `json:"title"`
+	Date       string `json:"date"` // YYYY-MM-DD upload date, or "" if unknown
+	Era        string `json:"era"`
+	Status     string `json:"status"`
+	VideoURL   string `json:"video_url"`
+	GuideURL   string `json:"guide_url,omitempty"`
+	DeckURL    string `json:"deck_url,omitempty"`
+	RepoURL    string `json:"repo_url,omitempty"`
+}
 
-	user := roster.String()
+// IndexJSON writes learning-labs-index.json: the same roster as the index
+// table, built directly from lab metadata and playlist info with no LLM call.
+func IndexJSON(cfg *config.Config, labs []data.LabMeta, playlistInfo map[string]collect.VideoInfo) error {
+	entries := make([]indexEntry, 0, len(labs))
+	for _, lab := range labs {
+		info := playlistInfo[lab.VideoID]
+		entries = append(entries, indexEntry{
+			ID:         lab.ID,
+			IDInferred: lab.IDInferred,
+			Title:      info.Title,
+			Date:       formatUploadDate(info.UploadDate),
+			Era:        lab.Era,
+			Status:     lab.Status,
+			VideoURL:   lab.RecordingURL(),
+			GuideURL:   lab.LabPageURL(),
+			DeckURL:    lab.DeckURL(),
+			RepoURL:    lab.GitHubURL(),
+		})
+	}
 
-	text, err := client.Generate(ctx, system, user, 4096)
+	out, err := marshalIndent(struct {
+		Labs []indexEntry `json:"labs"`
+	}{Labs: entries})
 	if err != nil {
-		return fmt.Errorf("generate index: %w", err)
+		return fmt.Errorf("marshal index: %w", err)
 	}
 
-	outPath := filepath.Join(cfg.OutputDir, "learning-labs-index.md")
-	if err := os.WriteFile(outPath, []byte(text), 0o644); err != nil {
+	outPath := filepath.Join(cfg.OutputDir, "learning-labs-index.json")
+	if err := atomicfile.WriteFile(outPath, out, cfg.FileMode); err != nil {
 		return fmt.Errorf("write %s: %w", outPath, err)
 	}
 	fmt.Printf("  wrote %s\n", outPath)
 	return nil
 }
+
+// formatUploadDate converts yt-dlp's YYYYMMDD to YYYY-MM-DD.
+func formatUploadDate(d string) string {
+	if len(d) != 8 {
+		return d
+	}
+	return d[:4] + "-" + d[4:6] + "-" + d[6:]
+}
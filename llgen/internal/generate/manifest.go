@@ -0,0 +1,153 @@
+package generate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"llgen/data"
+	"llgen/internal/atomicfile"
+	"llgen/internal/config"
+	"llgen/internal/transform"
+)
+
+// ManifestFile is the name of the output manifest in the output directory.
+const ManifestFile = "llgen-manifest.json"
+
+// Manifest is the on-disk shape of llgen-manifest.json: what produced each
+// generated file, for reproducibility audits.
+type Manifest struct {
+	UpdatedAt string           `json:"updated_at"`
+	Outputs   []ManifestOutput `json:"outputs"` // in config.OutputFiles order
+}
+
+// ManifestOutput records one generated file.
+type ManifestOutput struct {
+	File        string        `json:"file"`
+	SHA256      string        `json:"sha256"`
+	Model       string        `json:"model,omitempty"` // "" for files built without a model
+	GeneratedAt string        `json:"generated_at"`
+	Labs        []ManifestLab `json:"labs"`
+}
+
+// ManifestLab is a lab that fed an output. CorpusSHA256 is the hash of the
+// lab's corpus (see transform.LabCorpus.Hash) as built by the run that
+// wrote the output; a catalog entry served from the per-lab cache may have
+// been generated from an earlier corpus. It is "" when no run has built
+// the lab's corpus.
+type ManifestLab struct {
+	ID           string `json:"id"`
+	CorpusSHA256 string `json:"corpus_sha256,omitempty"`
+}
+
+// WriteManifest records the outputs written by this run in
+// llgen-manifest.json, keeping the entries of files it did not write.
+// corpora are the corpora this run built; labs it did not build keep the
+// corpus hash recorded by an earlier run.
+func WriteManifest(cfg *config.Config, written []string, corpora map[string]*transform.LabCorpus) error {
+	outPath := filepath.Join(cfg.OutputDir, ManifestFile)
+	var manifest Manifest
+	if b, err := os.ReadFile(outPath); err == nil {
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			return fmt.Errorf("parse existing %s: %w", outPath, err)
+		}
+	}
+
+	corpusHash := make(map[string]string)
+	for _, out := range manifest.Outputs {
+		for _, l := range out.Labs {
+			if l.CorpusSHA256 != "" {
+				corpusHash[l.ID] = l.CorpusSHA256
+			}
+		}
+	}
+	for id, c := range corpora {
+		corpusHash[id] = c.Hash()
+	}
+
+	byFile := make(map[string]ManifestOutput, len(manifest.Outputs))
+	for _, out := range manifest.Outputs {
+		byFile[out.File] = out
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, name := range written {
+		content, err := os.ReadFile(filepath.Join(cfg.OutputDir, name))
+		if err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+		sum := sha256.Sum256(content)
+		out := ManifestOutput{File: name, SHA256: hex.EncodeToString(sum[:]), Model: manifestModel(cfg, name), GeneratedAt: now}
+		ids, err := outputLabIDs(cfg, name)
+		if err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+		for _, id := range ids {
+			out.Labs = append(out.Labs, ManifestLab{ID: id, CorpusSHA256: corpusHash[id]})
+		}
+		byFile[name] = out
+	}
+
+	order := make(map[string]int, len(config.OutputFiles))
+	for i, name := range config.OutputFiles {
+		order[name] = i
+	}
+	manifest = Manifest{UpdatedAt: now}
+	for _, out := range byFile {
+		manifest.Outputs = append(manifest.Outputs, out)
+	}
+	sort.Slice(manifest.Outputs, func(i, j int) bool {
+		return order[manifest.Outputs[i].File] < order[manifest.Outputs[j].File]
+	})
+
+	b, err := marshalIndent(manifest)
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := atomicfile.WriteFile(outPath, b, cfg.FileMode); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("  wrote %s\n", outPath)
+	return nil
+}
+
+// manifestModel returns the model that generated the named output.
+func manifestModel(cfg *config.Config, name string) string {
+	switch name {
+	case "learning-labs-index.json":
+		return ""
+	case "labs-embeddings.json":
+		return cfg.EmbedModel
+	}
+	return cfg.ModelForOutput(name)
+}
+
+// outputLabIDs returns the labs the named output was built from: every lab
+// for the indexes, and the catalog's labs for the catalog and the outputs
+// derived from it.
+func outputLabIDs(cfg *config.Config, name string) ([]string, error) {
+	var ids []string
+	switch name {
+	case "learning-labs-index.md", "learning-labs-index.json":
+		for _, l := range data.Labs {
+			ids = append(ids, l.ID)
+		}
+		return ids, nil
+	}
+	b, err := os.ReadFile(filepath.Join(cfg.OutputDir, "labs-catalog.json"))
+	if err != nil {
+		return nil, err
+	}
+	var catalog catalogFile
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		return nil, fmt.Errorf("parse labs-catalog.json: %w", err)
+	}
+	for _, entry := range catalog.Labs {
+		ids = append(ids, entryID(entry))
+	}
+	return ids, nil
+}
@@ -0,0 +1,105 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+
+	"llgen/data"
+	"llgen/internal/config"
+)
+
+// Plan actions.
+const (
+	PlanReuse      = "reuse"      // cache is used as is
+	PlanRegenerate = "regenerate" // cache exists but will be overwritten
+	PlanGenerate   = "generate"   // no cache yet
+)
+
+// PlanItem is one cache file a run would read or write.
+type PlanItem struct {
+	Output string // output file, or "transcript", "guide", "deck-pdf" for collection caches
+	Lab    string // "" for whole-file caches
+	Path   string
+	Exists bool
+	Action string
+	Note   string // why, when not obvious from the action
+}
+
+// Plan lists the cache files a run with cfg would use for labs and the
+// selected outputs, and whether each would be reused or regenerated, by
+// the same rules the collectors and generators apply. It makes no network
+// or Claude calls. Caches keyed on inputs not known before the run (the
+// embedding text, the recommender's input hash) are reported as reused
+// with a note, since they are still regenerated if those inputs change.
+func Plan(cfg *config.Config, labs []data.LabMeta) []PlanItem {
+	var items []PlanItem
+	add := func(output, lab, path string, force bool, valid func([]byte) bool) *PlanItem {
+		it := PlanItem{Output: output, Lab: lab, Path: path, Action: PlanGenerate}
+		raw, err := os.ReadFile(path)
+		if err == nil {
+			it.Exists = true
+			switch {
+			case force:
+				it.Action = PlanRegenerate
+			case valid != nil && !valid(raw):
+				it.Action = PlanRegenerate
+				it.Note = "cache is invalid"
+			default:
+				it.Action = PlanReuse
+			}
+		}
+		items = append(items, it)
+		return &items[len(items)-1]
+	}
+
+	for _, lab := range labs {
+		add("transcript", lab.ID, filepath.Join(cfg.CacheDir, lab.VideoID+".en.vtt"), cfg.ForceCollectLab(lab.ID), nil)
+		if lab.GitHubID != "" {
+			add("guide", lab.ID, filepath.Join(cfg.GitHubCacheDir(), lab.GitHubID+".md"), cfg.ForceCollectLab(lab.GitHubID), nil)
+		}
+		if cfg.PDFDecks && lab.DeckPDFURL() != "" {
+			it := add("deck-pdf", lab.ID, filepath.Join(cfg.DeckCacheDir(), lab.ID+".pdf"), cfg.ForceCollectLab(lab.ID), nil)
+			if lab.DeckFile != "" && cfg.DecksDir != "" {
+				it.Note = "only used if the local deck has no text"
+			}
+		}
+	}
+
+	if cfg.Selected("learning-labs-index.md") {
+		items = append(items, PlanItem{
+			Output: "learning-labs-index.md",
+			Path:   filepath.Join(cfg.OutputDir, "learning-labs-index.md"),
+			Action: PlanGenerate,
+			Note:   "not cached; generated every run",
+		})
+	}
+
+	if cfg.Selected("labs-catalog.json") {
+		for _, lab := range labs {
+			add("labs-catalog.json", lab.ID, filepath.Join(cfg.CatalogCacheDir(), lab.ID+".json"), cfg.ForceGenerateLab(lab.ID), func(raw []byte) bool {
+				_, err := canonicalJSON(raw)
+				return err == nil
+			})
+		}
+	}
+
+	if cfg.Selected("labs-embeddings.json") {
+		for _, lab := range labs {
+			it := add("labs-embeddings.json", lab.ID, filepath.Join(cfg.EmbeddingsCacheDir(), lab.ID+".json"), cfg.ForceGenerateLab(lab.ID), nil)
+			if it.Action == PlanReuse {
+				it.Note = "unless the lab's catalog text or embedding model changed"
+			}
+		}
+	}
+
+	if cfg.Selected("recommender-system-prompt.md") {
+		it := add("recommender-system-prompt.md", "", filepath.Join(cfg.CacheDir, "recommender.sha256"), cfg.ForceGeneration(), nil)
+		if _, err := os.Stat(filepath.Join(cfg.OutputDir, "recommender-system-prompt.md")); err != nil && it.Action == PlanReuse {
+			it.Action = PlanRegenerate
+			it.Note = "output file is missing"
+		} else if it.Action == PlanReuse {
+			it.Note = "unless the catalog, caveats or prompt changed"
+		}
+	}
+	return items
+}
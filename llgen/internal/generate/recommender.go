@@ -2,12 +2,23 @@ package generate
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 
+	"llgen/data"
+	"llgen/internal/atomicfile"
 	"llgen/internal/claude"
 	"llgen/internal/config"
+	"llgen/internal/events"
+	"llgen/internal/logging"
+	"llgen/internal/prompts"
 )
 
 // hardcodedCaveats contains facts that cannot be derived from transcripts alone.
@@ -40,38 +51,181 @@ const hardcodedCaveats = `## Known Issues and Special Notes (inject into recomme
 - ll202509 is the recommended starting point for almost all personas.`
 
 // Recommender generates recommender-system-prompt.md from the catalog JSON + hardcoded caveats.
-func Recommender(ctx context.Context, client *claude.Client, cfg *config.Config) error {
+// Generation is skipped when the prompt inputs (catalog, caveats, settings,
+// model) hash to the value stored from the last run, unless generation is forced.
+func Recommender(ctx context.Context, client claude.Generator, cfg *config.Config) error {
 	catalogPath := filepath.Join(cfg.OutputDir, "labs-catalog.json")
 	catalogBytes, err := os.ReadFile(catalogPath)
 	if err != nil {
 		return fmt.Errorf("read labs-catalog.json (run catalog generation first): %w", err)
 	}
 
-	system := `You are writing a system prompt for an LLM-powered recommender that helps users find the right Chainguard Learning Lab.
+	system, err := prompts.Render(cfg.PromptsDir, prompts.RecommenderSystem, struct{ WorkedExamples int }{cfg.WorkedExamples})
+	if err != nil {
+		return err
+	}
+
+	caveats := hardcodedCaveats
+	if cfg.CaveatsFile != "" {
+		extra, err := os.ReadFile(cfg.CaveatsFile)
+		if err != nil {
+			return fmt.Errorf("caveats file: %w", err)
+		}
+		caveats = mergeCaveats(caveats, string(extra))
+	}
+	if !cfg.IncludeUnpublished {
+		caveats = dropUnknownLabCaveats(caveats)
+	}
 
-Produce a complete, self-contained system prompt document. The document should:
-1. Explain the recommender's purpose and constraints
-2. Define matching rules (by topic, difficulty, persona, technology)
-3. Specify how to handle edge cases (unpublished labs, broken labs, hardware requirements)
-4. Define the response format (brief lab description + direct link + one-line rationale)
-5. Include 3 worked examples showing query → recommendation reasoning
-6. Embed the catalog notes and known issues
+	var seeds string
+	if queries := exampleQueries(catalogBytes, cfg.WorkedExamples); len(queries) > 0 {
+		seeds = "## Seed Queries for Worked Examples\n\nBase the worked examples on these real intent signals from the catalog:\n- " +
+			strings.Join(queries, "\n- ") + "\n\n"
+	}
 
-The system prompt should be written in second person ("You are a lab recommender...").
-It should be comprehensive enough that an LLM with only this prompt and a user query can give good recommendations.`
+	user := fmt.Sprintf("## Labs Catalog (JSON)\n\n```json\n%s\n```\n\n## Known Issues and Caveats\n\n%s\n\n%sNow write the complete recommender system prompt document.",
+		string(catalogBytes), caveats, seeds)
 
-	user := fmt.Sprintf("## Labs Catalog (JSON)\n\n```json\n%s\n```\n\n## Known Issues and Caveats\n\n%s\n\nNow write the complete recommender system prompt document.",
-		string(catalogBytes), hardcodedCaveats)
+	outPath := filepath.Join(cfg.OutputDir, "recommender-system-prompt.md")
+	hashPath := filepath.Join(cfg.CacheDir, "recommender.sha256")
+	sum := sha256.Sum256([]byte(client.Model() + "\x00" + system + "\x00" + user))
+	inputHash := hex.EncodeToString(sum[:])
 
+	if !cfg.ForceGeneration() {
+		if stored, err := os.ReadFile(hashPath); err == nil && strings.TrimSpace(string(stored)) == inputHash {
+			if _, err := os.Stat(outPath); err == nil {
+				logging.Infof("  recommender: inputs unchanged (cached)\n")
+				events.Generation(events.GenerationCached, "recommender-system-prompt.md", "")
+				return nil
+			}
+		}
+	}
+
+	ctx = claude.WithLabel(ctx, "recommender-system-prompt.md", "")
+	events.Generation(events.GenerationStarted, "recommender-system-prompt.md", "")
 	text, err := client.Generate(ctx, system, user, 4096)
 	if err != nil {
 		return fmt.Errorf("generate recommender: %w", err)
 	}
+	events.Generation(events.GenerationCompleted, "recommender-system-prompt.md", "")
 
-	outPath := filepath.Join(cfg.OutputDir, "recommender-system-prompt.md")
-	if err := os.WriteFile(outPath, []byte(text), 0o644); err != nil {
+	if err := atomicfile.WriteFile(outPath, []byte(text), cfg.FileMode); err != nil {
 		return fmt.Errorf("write %s: %w", outPath, err)
 	}
+	// Record the hash only after the output is safely written.
+	if err := atomicfile.WriteFile(hashPath, []byte(inputHash+"\n"), 0o644); err != nil {
+		return fmt.Errorf("write recommender hash: %w", err)
+	}
 	fmt.Printf("  wrote %s\n", outPath)
 	return nil
 }
+
+// mergeCaveats folds extra, Markdown in hardcodedCaveats' shape, into base.
+// Each "### " section of extra replaces the base section with the same key
+// (the heading up to " — ", so "### ll202510" overrides "### ll202510 —
+// JavaScript/CVE Remediation"), or is appended when there is none. Text
+// before extra's first section, other than "## " headings, is appended too.
+func mergeCaveats(base, extra string) string {
+	baseHead, baseSecs := splitCaveats(base)
+	extraHead, extraSecs := splitCaveats(extra)
+
+	index := make(map[string]int, len(baseSecs))
+	for i, sec := range baseSecs {
+		index[caveatKey(sec)] = i
+	}
+	for _, sec := range extraSecs {
+		if i, ok := index[caveatKey(sec)]; ok {
+			baseSecs[i] = sec
+		} else {
+			index[caveatKey(sec)] = len(baseSecs)
+			baseSecs = append(baseSecs, sec)
+		}
+	}
+
+	var preamble []string
+	for _, line := range strings.Split(extraHead, "\n") {
+		if !strings.HasPrefix(line, "## ") {
+			preamble = append(preamble, line)
+		}
+	}
+	parts := []string{strings.TrimSpace(baseHead)}
+	for _, sec := range baseSecs {
+		parts = append(parts, strings.TrimSpace(sec))
+	}
+	if p := strings.TrimSpace(strings.Join(preamble, "\n")); p != "" {
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// splitCaveats splits s into the text before its first "### " heading and
+// the sections that start at each such heading.
+func splitCaveats(s string) (head string, sections []string) {
+	chunks := strings.Split("\n"+s, "\n### ")
+	for _, c := range chunks[1:] {
+		sections = append(sections, "### "+c)
+	}
+	return strings.TrimPrefix(chunks[0], "\n"), sections
+}
+
+// reLabID matches a lab ID such as ll202509.
+var reLabID = regexp.MustCompile(`^ll\d{6}$`)
+
+// dropUnknownLabCaveats removes the caveat sections for labs missing from
+// the lab map, such as the unpublished labs -include-unpublished=false
+// filters out, so the recommender never hears of them. Sections not keyed
+// by a lab ID ("Series notes") are kept.
+func dropUnknownLabCaveats(caveats string) string {
+	head, sections := splitCaveats(caveats)
+	parts := []string{strings.TrimSpace(head)}
+	for _, sec := range sections {
+		key := caveatKey(sec)
+		if reLabID.MatchString(key) && !slices.ContainsFunc(data.Labs, func(l data.LabMeta) bool { return l.ID == key }) {
+			continue
+		}
+		parts = append(parts, strings.TrimSpace(sec))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// caveatKey is a section's heading up to " — ", lowercased.
+func caveatKey(section string) string {
+	heading, _, _ := strings.Cut(strings.TrimPrefix(section, "### "), "\n")
+	key, _, _ := strings.Cut(heading, " — ")
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+// exampleQueries picks n intent signals from labs spread evenly across the
+// catalog, so worked examples are grounded in real labs and cover both eras.
+// The choice is deterministic for a given catalog.
+func exampleQueries(catalogBytes []byte, n int) []string {
+	var catalog struct {
+		Labs []struct {
+			IntentSignals []string `json:"intent_signals"`
+		} `json:"labs"`
+	}
+	if n <= 0 || json.Unmarshal(catalogBytes, &catalog) != nil {
+		return nil
+	}
+	var withSignals [][]string
+	for _, l := range catalog.Labs {
+		if len(l.IntentSignals) > 0 {
+			withSignals = append(withSignals, l.IntentSignals)
+		}
+	}
+	if len(withSignals) == 0 {
+		return nil
+	}
+
+	var queries []string
+	for i := 0; i < n; i++ {
+		lab, round := i*len(withSignals)/n, 0
+		if n > len(withSignals) {
+			// More examples than labs: cycle labs, taking the next signal each pass.
+			lab, round = i%len(withSignals), i/len(withSignals)
+		}
+		signals := withSignals[lab]
+		queries = append(queries, signals[round%len(signals)])
+	}
+	return queries
+}
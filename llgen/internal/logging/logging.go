@@ -0,0 +1,42 @@
+// Package logging provides llgen's three output levels: -quiet shows only
+// phase banners and warnings, the default adds per-lab progress and cache
+// hits, and -v adds full prompts and token counts.
+package logging
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Level controls how much per-lab detail is printed.
+type Level int32
+
+const (
+	Quiet Level = iota
+	Normal
+	Verbose
+)
+
+var level atomic.Int32
+
+func init() { level.Store(int32(Normal)) }
+
+// SetLevel sets the process-wide output level.
+func SetLevel(l Level) { level.Store(int32(l)) }
+
+// Enabled reports whether output at l should be printed.
+func Enabled(l Level) bool { return Level(level.Load()) >= l }
+
+// Infof prints per-lab detail such as cache hits; suppressed by -quiet.
+func Infof(format string, args ...any) {
+	if Enabled(Normal) {
+		fmt.Printf(format, args...)
+	}
+}
+
+// Debugf prints prompts, token counts and similar diagnostics; shown only with -v.
+func Debugf(format string, args ...any) {
+	if Enabled(Verbose) {
+		fmt.Printf(format, args...)
+	}
+}
@@ -0,0 +1,42 @@
+// Package pool runs indexed work items through a bounded set of goroutines.
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach calls fn(i) for every i in [0, n) using at most workers goroutines.
+// Callers collect results into index-addressed slices so output order stays
+// deterministic regardless of completion order.
+//
+// Once ctx is done no further items are dispatched; in-flight calls are left
+// to finish. Returns ctx.Err() if dispatch was cut short.
+func ForEach(ctx context.Context, workers, n int, fn func(i int)) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	var err error
+
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,126 @@
+// Package progress renders a per-item progress indicator with a rough ETA.
+//
+// On a terminal the indicator redraws a single line in place. When stdout is
+// not a TTY (CI logs, pipes) it degrades to one plain line per completed item.
+// Under -quiet only Printf messages are shown.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"llgen/internal/logging"
+)
+
+const barWidth = 20
+
+// Bar tracks completion of a fixed number of items. Safe for concurrent use.
+type Bar struct {
+	mu    sync.Mutex
+	out   io.Writer
+	tty   bool
+	quiet bool
+	label string
+	total int
+	done  int
+	start time.Time
+	last  string // last rendered line (TTY mode), redrawn after Printf
+}
+
+// New returns a Bar for total items writing to stdout.
+func New(label string, total int) *Bar {
+	return &Bar{
+		out:   os.Stdout,
+		tty:   isTerminal(os.Stdout),
+		quiet: !logging.Enabled(logging.Normal),
+		label: label,
+		total: total,
+		start: time.Now(),
+	}
+}
+
+// Step marks one item complete. item is shown alongside the counter.
+func (b *Bar) Step(item string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done++
+	if b.quiet {
+		return
+	}
+	line := b.render(item)
+	if b.tty {
+		b.last = line
+		fmt.Fprintf(b.out, "\r\033[K%s", line)
+		return
+	}
+	fmt.Fprintln(b.out, line)
+}
+
+// Printf writes a message on its own line without corrupting the bar.
+func (b *Bar) Printf(format string, args ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tty && b.last != "" {
+		fmt.Fprint(b.out, "\r\033[K")
+	}
+	fmt.Fprintf(b.out, format, args...)
+	if b.tty && b.last != "" {
+		fmt.Fprint(b.out, b.last)
+	}
+}
+
+// Done finishes the bar, leaving the final state on screen.
+func (b *Bar) Done() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tty && b.last != "" {
+		fmt.Fprintln(b.out)
+		b.last = ""
+	}
+}
+
+func (b *Bar) render(item string) string {
+	pct := 100
+	if b.total > 0 {
+		pct = b.done * 100 / b.total
+	}
+
+	var sb strings.Builder
+	sb.WriteString("  " + b.label + " ")
+	if b.tty {
+		filled := pct * barWidth / 100
+		sb.WriteString("[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + "] ")
+	}
+	fmt.Fprintf(&sb, "%3d%% %d/%d", pct, b.done, b.total)
+	if eta := b.eta(); eta > 0 {
+		fmt.Fprintf(&sb, " ETA %s", eta.Round(time.Second))
+	}
+	if item != "" {
+		sb.WriteString("  " + item)
+	}
+	return sb.String()
+}
+
+// eta estimates remaining time from the average per-item duration so far.
+func (b *Bar) eta() time.Duration {
+	if b.done == 0 || b.done >= b.total {
+		return 0
+	}
+	avg := time.Since(b.start) / time.Duration(b.done)
+	return avg * time.Duration(b.total-b.done)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,56 @@
+// Package prompts renders the generators' system prompts from text/template
+// files. The defaults are embedded from defaults/; -prompts-dir may hold
+// same-named files that replace them, so prompt wording can be iterated on
+// without recompiling.
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Prompt names, each the base name of a .tmpl file, with the fields their
+// templates are executed against.
+const (
+	CatalogSystem     = "catalog-system"     // Schema, Example
+	IndexSystem       = "index-system"       // LabCount, OldFormat, NewFormat
+	RecommenderSystem = "recommender-system" // WorkedExamples
+	CompareSystem     = "compare-system"     // no fields
+)
+
+//go:embed defaults/*.tmpl
+var defaults embed.FS
+
+// Render executes prompt name with data. The template is dir/<name>.tmpl
+// when dir is set and that file exists, else the embedded default. A
+// trailing newline, which editors add, is dropped.
+func Render(dir, name string, data any) (string, error) {
+	file := name + ".tmpl"
+	src, err := defaults.ReadFile("defaults/" + file)
+	if err != nil {
+		return "", fmt.Errorf("unknown prompt %q", name)
+	}
+	if dir != "" {
+		override, err := os.ReadFile(filepath.Join(dir, file))
+		switch {
+		case err == nil:
+			src = override
+		case !os.IsNotExist(err):
+			return "", fmt.Errorf("prompt %s: %w", name, err)
+		}
+	}
+
+	tmpl, err := template.New(file).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return "", fmt.Errorf("prompt %s: %w", name, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", name, err)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
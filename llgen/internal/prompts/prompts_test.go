@@ -0,0 +1,48 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderDefault(t *testing.T) {
+	got, err := Render("", IndexSystem, struct{ LabCount, OldFormat, NewFormat int }{30, 12, 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "for all 30 labs") || !strings.Contains(got, "(video-only, 12 labs)") {
+		t.Errorf("fields not interpolated:\n%s", got)
+	}
+	if strings.HasSuffix(got, "\n") {
+		t.Error("trailing newline not trimmed")
+	}
+}
+
+func TestRenderOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, RecommenderSystem+".tmpl"), []byte("Give {{.WorkedExamples}} examples.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Render(dir, RecommenderSystem, struct{ WorkedExamples int }{4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Give 4 examples." {
+		t.Errorf("got %q", got)
+	}
+
+	// Prompts without a file in dir keep the embedded default.
+	got, err = Render(dir, CatalogSystem, struct{ Schema, Example string }{"S", "E"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == "" || strings.Contains(got, "{{") {
+		t.Errorf("default catalog prompt not rendered: %q", got)
+	}
+
+	if _, err := Render(dir, RecommenderSystem, struct{}{}); err == nil {
+		t.Error("missing field: want error")
+	}
+}
@@ -0,0 +1,65 @@
+// Package query runs a user question through the generated recommender, so the
+// system prompt can be sanity-checked without pasting it into a chat UI.
+package query
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"llgen/internal/claude"
+	"llgen/internal/config"
+)
+
+// Ask loads recommender-system-prompt.md and labs-catalog.json from the output
+// directory and returns Claude's recommendation for question. With
+// cfg.Explain the answer ends with the rationale for the choice.
+func Ask(ctx context.Context, client claude.Generator, cfg *config.Config, question string) (string, error) {
+	promptPath := filepath.Join(cfg.OutputDir, "recommender-system-prompt.md")
+	prompt, err := os.ReadFile(promptPath)
+	if err != nil {
+		return "", fmt.Errorf("read recommender-system-prompt.md (run generation first): %w", err)
+	}
+	catalogPath := filepath.Join(cfg.OutputDir, "labs-catalog.json")
+	catalog, err := os.ReadFile(catalogPath)
+	if err != nil {
+		return "", fmt.Errorf("read labs-catalog.json (run generation first): %w", err)
+	}
+
+	// The recommender prompt is written to stand alone, but attaching the
+	// catalog mirrors how a production recommender would be deployed.
+	system := fmt.Sprintf("%s\n\n## Labs Catalog (JSON)\n\n```json\n%s\n```", prompt, catalog)
+
+	ctx = claude.WithLabel(ctx, "query", "")
+	if cfg.Explain {
+		return explain(ctx, client, system, question)
+	}
+	text, err := client.Generate(ctx, system, question, 2048)
+	if err != nil {
+		return "", fmt.Errorf("query: %w", err)
+	}
+	return text, nil
+}
+
+// explainInstructions are appended to the question for -explain, so the
+// answer shows which catalog fields drove the choice.
+const explainInstructions = `
+
+After your recommendation, add a section headed "## Why" with 2-4 short bullets that explain the routing decision by quoting the catalog fields that matched: which of the chosen lab's intent_signals the request matched, which of its personas the asker fits, and why the closest runner-up lab (by id) lost.`
+
+// explain asks with extended thinking so the model weighs the candidates
+// before answering, falling back to standard generation like the catalog
+// generator does.
+func explain(ctx context.Context, client claude.Generator, system, question string) (string, error) {
+	user := question + explainInstructions
+	text, err := client.GenerateWithThinking(ctx, system, user, 4096, 2048)
+	if err != nil {
+		claude.RecordFallback(ctx)
+		text, err = client.Generate(ctx, system, user, 2048)
+		if err != nil {
+			return "", fmt.Errorf("query: %w", err)
+		}
+	}
+	return text, nil
+}
@@ -0,0 +1,119 @@
+// Package report collects non-fatal issues encountered during a run so they
+// can be summarized per lab at the end instead of scrolling past as warnings.
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"llgen/internal/atomicfile"
+	"llgen/internal/events"
+)
+
+// general is the heading used for issues not tied to a specific lab.
+const general = "(general)"
+
+// Issues accumulates errors grouped by lab ID. Safe for concurrent use.
+type Issues struct {
+	mu    sync.Mutex
+	byLab map[string][]error
+}
+
+// New returns an empty Issues collection.
+func New() *Issues {
+	return &Issues{byLab: make(map[string][]error)}
+}
+
+// Add records err against lab and emits it as an error event. An empty lab
+// records a run-wide issue.
+func (r *Issues) Add(lab string, err error) {
+	events.Fail(lab, err)
+	if lab == "" {
+		lab = general
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byLab[lab] = append(r.byLab[lab], err)
+}
+
+// Addf records a formatted issue against lab.
+func (r *Issues) Addf(lab, format string, args ...any) {
+	r.Add(lab, fmt.Errorf(format, args...))
+}
+
+// Len returns the total number of recorded issues.
+func (r *Issues) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, errs := range r.byLab {
+		n += len(errs)
+	}
+	return n
+}
+
+// labs returns lab keys with run-wide issues first, then newest lab first
+// (matching data.Labs order, since IDs are llYYYYMM).
+func (r *Issues) labs() []string {
+	keys := make([]string, 0, len(r.byLab))
+	for k := range r.byLab {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == general || keys[j] == general {
+			return keys[i] == general && keys[j] != general
+		}
+		return keys[i] > keys[j]
+	})
+	return keys
+}
+
+// Print writes a plain-text "Issues encountered" summary to w.
+func (r *Issues) Print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.byLab) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "==> Issues encountered:")
+	for _, lab := range r.labs() {
+		fmt.Fprintf(w, "  %s\n", lab)
+		for _, err := range r.byLab[lab] {
+			fmt.Fprintf(w, "    - %v\n", err)
+		}
+	}
+}
+
+// Markdown renders the issues as a run-report.md document.
+func (r *Issues) Markdown() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("# llgen run report\n\n")
+	if len(r.byLab) == 0 {
+		sb.WriteString("No issues encountered.\n")
+		return sb.String()
+	}
+	for _, lab := range r.labs() {
+		fmt.Fprintf(&sb, "## %s\n\n", lab)
+		for _, err := range r.byLab[lab] {
+			fmt.Fprintf(&sb, "- %v\n", err)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// WriteMarkdown writes the Markdown report to path with permissions perm.
+func (r *Issues) WriteMarkdown(path string, perm os.FileMode) error {
+	if err := atomicfile.WriteFile(path, []byte(r.Markdown()), perm); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package runlock
+
+import "os"
+
+// alive reports whether a process with pid exists. FindProcess opens the
+// process on Windows, so it fails once the process has exited.
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package runlock
+
+import (
+	"os"
+	"syscall"
+)
+
+// alive reports whether a process with pid exists.
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
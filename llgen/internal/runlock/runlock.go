@@ -0,0 +1,62 @@
+// Package runlock keeps two llgen runs from sharing a cache directory, where
+// they would race on the same per-lab cache files.
+package runlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the lock file created in the cache directory.
+const FileName = "llgen.lock"
+
+// Acquire creates dir/FileName holding this process's PID, failing if
+// another live process holds it. A lock left by a process that has exited
+// (killed, or stopped by log.Fatal) is taken over, as is one holding this
+// process's own PID: in a container every run is PID 1, so that lock was
+// left by an earlier run that crashed. Call the returned release func when
+// the run ends.
+func Acquire(dir string) (release func(), err error) {
+	path := filepath.Join(dir, FileName)
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			fmt.Fprintf(f, "%d %s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create lock %s: %w", path, err)
+		}
+
+		pid, started := readLock(path)
+		if pid > 0 && pid != os.Getpid() && alive(pid) {
+			return nil, fmt.Errorf("another llgen run (pid %d, started %s) holds the lock %s; wait for it to finish, or use a different -cache-dir", pid, started, path)
+		}
+		// Stale: its owner is gone. Remove it and retry once.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove stale lock %s: %w", path, err)
+		}
+	}
+	return nil, fmt.Errorf("could not acquire lock %s", path)
+}
+
+// readLock returns the PID and start time recorded in a lock file, or 0 if
+// it is unreadable.
+func readLock(path string) (int, string) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, ""
+	}
+	pidText, started, _ := strings.Cut(strings.TrimSpace(string(b)), " ")
+	pid, err := strconv.Atoi(pidText)
+	if err != nil {
+		return 0, ""
+	}
+	return pid, started
+}
@@ -0,0 +1,50 @@
+package runlock
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquireRejectsLiveHolderAndTakesOverStale(t *testing.T) {
+	dir := t.TempDir()
+	lock := filepath.Join(dir, FileName)
+
+	// The parent process (go test) stands in for another live run.
+	if err := os.WriteFile(lock, []byte(strconv.Itoa(os.Getppid())+" 2026-01-01T00:00:00Z\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Acquire(dir); err == nil || !strings.Contains(err.Error(), "another llgen run") {
+		t.Errorf("Acquire err = %v, want the lock to be held", err)
+	}
+
+	// A lock whose owner has exited is taken over.
+	if err := os.WriteFile(lock, []byte("999999999 2026-01-01T00:00:00Z\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	release, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire over stale lock: %v", err)
+	}
+	release()
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Errorf("lock file remains after release: %v", err)
+	}
+}
+
+func TestAcquireTakesOverOwnPID(t *testing.T) {
+	// A crashed run in a container leaves a lock holding PID 1, which the
+	// next run in a fresh container also gets.
+	dir := t.TempDir()
+	lock := filepath.Join(dir, FileName)
+	if err := os.WriteFile(lock, []byte(strconv.Itoa(os.Getpid())+" 2026-01-01T00:00:00Z\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	release, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire over own-PID lock: %v", err)
+	}
+	release()
+}
@@ -0,0 +1,80 @@
+package transform
+
+import "strings"
+
+// CodeBlock is one fenced code block from a lab guide.
+type CodeBlock struct {
+	Language string // info string after the opening fence, e.g. "bash"; "" if none
+	Code     string // block content without the fences
+}
+
+// shellLanguages are the fence languages whose blocks are commands to run.
+// Untagged blocks count too: the guides often leave shell blocks bare.
+var shellLanguages = map[string]bool{
+	"": true, "bash": true, "sh": true, "shell": true, "console": true, "zsh": true, "terminal": true,
+}
+
+// Runnable reports whether the block holds shell commands rather than file
+// contents or output.
+func (b CodeBlock) Runnable() bool {
+	return shellLanguages[strings.ToLower(b.Language)] && strings.TrimSpace(b.Code) != ""
+}
+
+// ExtractCodeBlocks returns the fenced code blocks in markdown, in order.
+// Both ``` and ~~~ fences are recognized; a block is closed only by a fence
+// of the same character at least as long as the one that opened it, and an
+// unclosed block runs to the end of the document.
+func ExtractCodeBlocks(markdown string) []CodeBlock {
+	var blocks []CodeBlock
+	var fence string // opening fence of the current block, "" outside one
+	var block CodeBlock
+	var body []string
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if f := fencePrefix(trimmed); f != "" {
+				fence = f
+				block, body = CodeBlock{}, nil
+				if info := strings.Fields(trimmed[len(f):]); len(info) > 0 {
+					block.Language = info[0]
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			block.Code = strings.Join(body, "\n")
+			blocks = append(blocks, block)
+			fence = ""
+			continue
+		}
+		body = append(body, line)
+	}
+	if fence != "" {
+		block.Code = strings.Join(body, "\n")
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// fencePrefix returns the run of three or more backticks or tildes opening
+// line, or "".
+func fencePrefix(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+// RunnableCommands returns the runnable blocks among blocks.
+func RunnableCommands(blocks []CodeBlock) []CodeBlock {
+	var out []CodeBlock
+	for _, b := range blocks {
+		if b.Runnable() {
+			out = append(out, b)
+		}
+	}
+	return out
+}
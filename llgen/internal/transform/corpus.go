@@ -1,8 +1,14 @@
 package transform
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"llgen/data"
 	"llgen/internal/collect"
@@ -11,39 +17,168 @@ import (
 
 // LabCorpus aggregates all available text content for one lab.
 type LabCorpus struct {
-	Lab        data.LabMeta
-	Title      string // from playlist metadata
-	UploadDate string // YYYYMMDD from playlist metadata
+	Lab         data.LabMeta
+	Title       string // never empty after BuildCorpus; see TitleSource
+	TitleSource string // "playlist", "guide", "deck" or "lab ID"
+	UploadDate  string // YYYYMMDD from playlist metadata
 
-	Transcript string // full plain-text transcript (from VTT)
-	GitHubGuide string // markdown from GitHub
-	DeckText   string // extracted PPTX slide text
+	Transcript  string      // full plain-text transcript (from VTT)
+	Segments    []Segment   // the same transcript with cue start times
+	GitHubGuide string      // markdown from GitHub
+	CodeBlocks  []CodeBlock // fenced code blocks in GitHubGuide, in order
+	DeckText    string      // extracted PPTX slide text
+	DeckShared  []string    // other labs whose DeckFile is the same template deck
+	Description string      // YouTube video description saved with the transcript
+
+	Sources  []string // sources actually loaded, in order: "transcript", "guide", "deck"
+	Warnings []string // non-fatal problems found while building, e.g. a degraded transcript
+}
+
+// EstimateTokens approximates the token count of s as words × 1.3, close
+// enough to compare labs and tune excerpt and budget flags.
+func EstimateTokens(s string) int {
+	return len(strings.Fields(s)) * 13 / 10
+}
+
+// Hash returns the hex SHA-256 of the corpus text sent to generation
+// (title, description, transcript, guide and deck), so outputs can be tied
+// to the corpus state they were built from.
+func (c *LabCorpus) Hash() string {
+	h := sha256.New()
+	for _, s := range []string{c.Title, c.Description, c.Transcript, c.GitHubGuide, c.DeckText} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// corpusSources are the source names BuildCorpus may record in Sources.
+var corpusSources = []string{"transcript", "guide", "deck"}
+
+// HasSource reports whether the named source was loaded.
+func (c *LabCorpus) HasSource(name string) bool {
+	for _, s := range c.Sources {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceSummary describes what the corpus was built from, e.g.
+// "transcript, guide, deck" or "transcript only, no guide/deck".
+func (c *LabCorpus) SourceSummary() string {
+	var missing []string
+	for _, s := range corpusSources {
+		if !c.HasSource(s) {
+			missing = append(missing, s)
+		}
+	}
+	switch {
+	case len(c.Sources) == 0:
+		return "no sources"
+	case len(missing) == 0:
+		return strings.Join(c.Sources, ", ")
+	case len(c.Sources) == 1:
+		return c.Sources[0] + " only, no " + strings.Join(missing, "/")
+	}
+	return strings.Join(c.Sources, ", ") + "; no " + strings.Join(missing, "/")
+}
+
+// TranscriptExcerpt returns the first head characters of the transcript and,
+// when tail > 0, its last tail characters, joined by an elision marker so the
+// lab's wrap-up survives truncation. Returns the full transcript if it fits.
+// Cuts fall on word boundaries so excerpts never end mid-word.
+func (c *LabCorpus) TranscriptExcerpt(head, tail int) string {
+	return excerpt(c.Transcript, head, tail)
+}
+
+// TimecodedExcerpt is TranscriptExcerpt over the timecoded transcript (see
+// Timecoded), so the excerpt keeps its "[m:ss]" markers.
+func (c *LabCorpus) TimecodedExcerpt(head, tail int) string {
+	return excerpt(Timecoded(c.Segments, timecodeInterval), head, tail)
+}
+
+// timecodeInterval is the minimum spacing between timecodes in prompts:
+// frequent enough to cite a step, sparse enough not to bloat the input.
+const timecodeInterval = 30 * time.Second
+
+func excerpt(s string, head, tail int) string {
+	if len(s) <= head+tail {
+		return s
+	}
+	out := s[:cutBefore(s, head)]
+	if tail <= 0 {
+		return out
+	}
+	return out + "\n[...]\n" + s[cutAfter(s, len(s)-tail):]
+}
+
+// cutBefore returns the largest index <= n at which s can be cut without
+// splitting a word. Falls back to n when the first n bytes hold no whitespace.
+func cutBefore(s string, n int) int {
+	if i := strings.LastIndexAny(s[:n+1], " \t\n"); i > 0 {
+		return i
+	}
+	return n
 }
 
-// TranscriptExcerpt returns the first n characters of the transcript.
-func (c *LabCorpus) TranscriptExcerpt(n int) string {
-	if len(c.Transcript) <= n {
-		return c.Transcript
+// cutAfter returns the smallest index >= n at which s can be cut without
+// splitting a word. Falls back to n when no whitespace follows.
+func cutAfter(s string, n int) int {
+	if n == 0 || strings.ContainsAny(s[n-1:n], " \t\n") {
+		return n
+	}
+	if i := strings.IndexAny(s[n:], " \t\n"); i >= 0 {
+		return n + i + 1
 	}
-	return c.Transcript[:n]
+	return n
 }
 
 // BuildCorpus assembles a LabCorpus for a single lab by reading cached files.
 // Missing files are silently skipped (transcript, guide, deck are all optional).
-func BuildCorpus(cfg *config.Config, lab data.LabMeta) (*LabCorpus, error) {
-	corpus := &LabCorpus{Lab: lab}
+// With cfg.PDFDecks, a lab with no local PPTX gets its deck text from the
+// public deck PDF instead.
+//
+// info is the lab's playlist entry, zero when yt-dlp failed or the video has
+// left the playlist. Its title is used when present, else the guide's H1,
+// else the deck's first slide, else the lab ID, so generators never see a
+// blank title.
+func BuildCorpus(ctx context.Context, cfg *config.Config, lab data.LabMeta, info collect.VideoInfo) (*LabCorpus, error) {
+	corpus := &LabCorpus{Lab: lab, UploadDate: info.UploadDate}
+	var deckTitle string
 
 	// Load transcript
-	transcript, err := loadTranscript(cfg, lab.VideoID)
+	transcript, segments, err := loadTranscript(cfg, lab.VideoID)
 	if err == nil {
-		corpus.Transcript = transcript
+		// yt-dlp can "succeed" with a near-empty VTT when the video needs
+		// sign-in; sending that to Claude yields a hallucinated entry.
+		if n := len(strings.Fields(transcript)); cfg.MinTranscriptWords > 0 && n < cfg.MinTranscriptWords {
+			corpus.Warnings = append(corpus.Warnings, fmt.Sprintf(
+				"transcript has only %d words (minimum %d); skipped as degraded — delete %s.en.vtt to re-download",
+				n, cfg.MinTranscriptWords, lab.VideoID))
+		} else {
+			corpus.Transcript = transcript
+			corpus.Segments = segments
+			corpus.Sources = append(corpus.Sources, "transcript")
+		}
 	}
 
-	// Load GitHub guide
+	// The description DownloadTranscript saved alongside the VTT often
+	// names the presenter ("Presented by ..."); no extra fetch is needed.
+	if cfg.VideoDescription {
+		if raw, err := os.ReadFile(filepath.Join(cfg.CacheDir, lab.VideoID+".description")); err == nil {
+			corpus.Description = strings.TrimSpace(string(raw))
+		}
+	}
+
+	// Load GitHub guide, fetched into the cache during collection.
 	if lab.GitHubID != "" {
-		guide, err := collect.FetchGitHubGuide(cfg, lab.GitHubID)
-		if err == nil {
+		guide, err := collect.ReadGitHubGuide(cfg, lab.GitHubID)
+		if err == nil && guide != "" {
 			corpus.GitHubGuide = guide
+			corpus.CodeBlocks = ExtractCodeBlocks(guide)
+			corpus.Sources = append(corpus.Sources, "guide")
 		}
 	}
 
@@ -53,19 +188,95 @@ func BuildCorpus(cfg *config.Config, lab data.LabMeta) (*LabCorpus, error) {
 		slides, err := collect.ParsePPTX(deckPath)
 		if err == nil && len(slides) > 0 {
 			corpus.DeckText = collect.SlidesToText(slides)
+			deckTitle = firstSlideTitle(slides)
+			for _, id := range SharedDecks(data.Labs)[lab.DeckFile] {
+				if id != lab.ID {
+					corpus.DeckShared = append(corpus.DeckShared, id)
+				}
+			}
+		}
+	}
+
+	// Fall back to the published PDF when there is no local PPTX.
+	if corpus.DeckText == "" && cfg.PDFDecks && lab.DeckPDFURL() != "" {
+		path, err := collect.FetchDeckPDF(ctx, cfg, lab.ID, lab.DeckPDFURL())
+		if err == nil && path != "" {
+			slides, err := collect.ParsePDF(ctx, cfg.PdftotextPath, path)
+			if err != nil {
+				corpus.Warnings = append(corpus.Warnings, fmt.Sprintf("deck PDF: %v", err))
+			} else if len(slides) > 0 {
+				corpus.DeckText = collect.SlidesToText(slides)
+				deckTitle = firstSlideTitle(slides)
+			}
 		}
 	}
 
+	if corpus.DeckText != "" {
+		corpus.Sources = append(corpus.Sources, "deck")
+	}
+
+	for _, t := range []struct{ title, source string }{
+		{info.Title, "playlist"},
+		{guideTitle(corpus.GitHubGuide), "guide"},
+		{deckTitle, "deck"},
+		{lab.ID, "lab ID"},
+	} {
+		if title := strings.TrimSpace(t.title); title != "" {
+			corpus.Title, corpus.TitleSource = title, t.source
+			break
+		}
+	}
 	return corpus, nil
 }
 
-// loadTranscript reads and converts a VTT file to plain text.
+// guideTitle returns the text of the first "# " heading in markdown, or "".
+func guideTitle(markdown string) string {
+	for _, line := range strings.Split(markdown, "\n") {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+			return title
+		}
+	}
+	return ""
+}
+
+// firstSlideTitle returns the first non-blank line of the first slide, which
+// on the lab decks is the title slide.
+func firstSlideTitle(slides []collect.SlideText) string {
+	for _, line := range slides[0].Lines {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// loadTranscript reads and converts a VTT file to plain text and to
+// timecoded segments.
 // Searches the cache dir in flat layout: <cacheDir>/<videoID>.en.vtt
-func loadTranscript(cfg *config.Config, videoID string) (string, error) {
+func loadTranscript(cfg *config.Config, videoID string) (string, []Segment, error) {
 	vttPath := filepath.Join(cfg.CacheDir, videoID+".en.vtt")
 	raw, err := os.ReadFile(vttPath)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	dedup := Dedup(cfg.VTTDedup)
+	return VTTToText(string(raw), dedup), VTTToSegments(string(raw), dedup), nil
+}
+
+// SharedDecks maps each DeckFile used by more than one of labs to the IDs of
+// those labs, in order. Such decks are reused templates (e.g. the Python
+// deck), so their text says little about any one lab.
+func SharedDecks(labs []data.LabMeta) map[string][]string {
+	byDeck := make(map[string][]string)
+	for _, l := range labs {
+		if l.DeckFile != "" {
+			byDeck[l.DeckFile] = append(byDeck[l.DeckFile], l.ID)
+		}
+	}
+	for deck, ids := range byDeck {
+		if len(ids) < 2 {
+			delete(byDeck, deck)
+		}
 	}
-	return VTTToText(string(raw)), nil
+	return byDeck
 }
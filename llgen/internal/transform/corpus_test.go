@@ -0,0 +1,91 @@
+package transform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"llgen/data"
+	"llgen/internal/collect"
+	"llgen/internal/config"
+)
+
+func TestSourceSummary(t *testing.T) {
+	tests := []struct {
+		sources []string
+		want    string
+	}{
+		{nil, "no sources"},
+		{[]string{"transcript"}, "transcript only, no guide/deck"},
+		{[]string{"transcript", "deck"}, "transcript, deck; no guide"},
+		{[]string{"transcript", "guide", "deck"}, "transcript, guide, deck"},
+	}
+	for _, tt := range tests {
+		c := &LabCorpus{Sources: tt.sources}
+		if got := c.SourceSummary(); got != tt.want {
+			t.Errorf("SourceSummary(%q) = %q, want %q", tt.sources, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCorpusTitleFallback(t *testing.T) {
+	cfg := &config.Config{CacheDir: t.TempDir(), Offline: true}
+	lab := data.LabMeta{ID: "ll202509", VideoID: "vid", GitHubID: "ll202509"}
+
+	corpus, err := BuildCorpus(context.Background(), cfg, lab, collect.VideoInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Title != "ll202509" || corpus.TitleSource != "lab ID" {
+		t.Errorf("no sources: title %q from %q, want lab ID", corpus.Title, corpus.TitleSource)
+	}
+
+	if err := os.MkdirAll(cfg.GitHubCacheDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	guide := "Intro text\n\n# Static Images\n\n## Steps\n"
+	if err := os.WriteFile(filepath.Join(cfg.GitHubCacheDir(), "ll202509.md"), []byte(guide), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err = BuildCorpus(context.Background(), cfg, lab, collect.VideoInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Title != "Static Images" || corpus.TitleSource != "guide" {
+		t.Errorf("guide only: title %q from %q, want guide H1", corpus.Title, corpus.TitleSource)
+	}
+
+	corpus, err = BuildCorpus(context.Background(), cfg, lab, collect.VideoInfo{Title: "Playlist Title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Title != "Playlist Title" || corpus.TitleSource != "playlist" {
+		t.Errorf("with playlist: title %q from %q", corpus.Title, corpus.TitleSource)
+	}
+}
+
+func TestExtractCodeBlocks(t *testing.T) {
+	guide := "# Lab\n\n```bash\ndocker build -t app .\ndocker run app\n```\n\n" +
+		"````dockerfile\nFROM cgr.dev/chainguard/static\n```\nstill inside\n````\n\n" +
+		"~~~\ngrype app\n~~~\n\n```yaml title=\"x\"\nkey: value\n"
+	blocks := ExtractCodeBlocks(guide)
+
+	want := []CodeBlock{
+		{"bash", "docker build -t app .\ndocker run app"},
+		{"dockerfile", "FROM cgr.dev/chainguard/static\n```\nstill inside"},
+		{"", "grype app"},
+		{"yaml", "key: value\n"},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("got %d blocks %q, want %d", len(blocks), blocks, len(want))
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("block %d = %q, want %q", i, blocks[i], want[i])
+		}
+	}
+	if got := RunnableCommands(blocks); len(got) != 2 || got[0].Language != "bash" || got[1].Code != "grype app" {
+		t.Errorf("RunnableCommands = %q, want the bash and untagged blocks", got)
+	}
+}
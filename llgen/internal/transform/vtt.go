@@ -2,8 +2,12 @@ package transform
 
 import (
 	"bufio"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 var (
@@ -11,25 +15,83 @@ var (
 	inlineTagRe = regexp.MustCompile(`<[^>]+>`)
 	// timestampLineRe matches VTT timestamp lines: "00:00:01.520 --> 00:00:04.000"
 	timestampLineRe = regexp.MustCompile(`^\d+:\d+`)
+	// wordTimeRe matches the inline word timing of YouTube auto-captions.
+	wordTimeRe = regexp.MustCompile(`<\d+:\d+[:.\d]*><c>`)
 )
 
-// VTTToText converts a YouTube auto-generated VTT transcript to clean prose.
+// Dedup selects how repeated caption lines are removed.
+type Dedup string
+
+const (
+	// DedupAuto uses DedupRolling for YouTube auto-captions, recognized by
+	// their inline word-timing tags, and DedupNone for anything else, such
+	// as uploaded or SRT-converted captions. Both kinds carry the same
+	// "Kind: captions" header, so the header alone can't tell them apart.
+	DedupAuto Dedup = "auto"
+	// DedupRolling drops a line when the next one starts with it: each
+	// auto-caption cue repeats the previous sentence before adding words.
+	DedupRolling Dedup = "rolling"
+	// DedupNone keeps every line.
+	DedupNone Dedup = "none"
+	// DedupFuzzy is DedupRolling comparing lines without regard to case,
+	// punctuation or spacing, for captions re-flowed by an editor.
+	DedupFuzzy Dedup = "fuzzy"
+)
+
+// VTTToText converts a VTT transcript to clean prose.
 //
-// YouTube VTT has two challenges handled here:
+// YouTube auto-generated VTT has two challenges handled here:
 //  1. Inline word-timing tags (stripped with inlineTagRe)
 //  2. Rolling duplicates: each cue emits the previous sentence before adding
-//     new words. Deduplicated by checking if line[n] is a prefix of line[n+1].
-func VTTToText(vttContent string) string {
-	var lines []string
+//     new words. Removed as dedup selects; see Dedup.
+func VTTToText(vttContent string, dedup Dedup) string {
+	segments := VTTToSegments(vttContent, dedup)
+	texts := make([]string, len(segments))
+	for i, seg := range segments {
+		texts[i] = seg.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// Segment is a deduplicated run of transcript text and the time its first
+// cue starts.
+type Segment struct {
+	Start time.Duration
+	Text  string
+}
+
+// cueTimeRe captures a cue's start time: "00:01:02.500 --> ..." or "01:02.500 --> ...".
+var cueTimeRe = regexp.MustCompile(`^(?:(\d+):)?(\d+):(\d+)\.(\d+)\s+-->`)
+
+// VTTToSegments is VTTToText with timing kept: it strips tags and removes
+// duplicates the same way, and stamps each surviving line with the start of
+// the earliest cue that began it. Joining the segments' Text with spaces
+// yields VTTToText's output.
+func VTTToSegments(vttContent string, dedup Dedup) []Segment {
+	var lines []Segment
+	var cueStart time.Duration
+	wordTimed := false
 	scanner := bufio.NewScanner(strings.NewReader(vttContent))
 	for scanner.Scan() {
-		raw := scanner.Text()
-
-		// Strip inline timing/styling tags
-		cleaned := inlineTagRe.ReplaceAllString(raw, "")
-		cleaned = strings.TrimSpace(cleaned)
+		raw := strings.TrimSpace(scanner.Text())
+		if m := cueTimeRe.FindStringSubmatch(raw); m != nil {
+			cueStart = parseCueTime(m)
+			continue
+		}
+		if wordTimeRe.MatchString(raw) {
+			wordTimed = true
+		}
 
-		// Skip structural lines
+		cleaned := strings.TrimSpace(inlineTagRe.ReplaceAllString(raw, ""))
 		if cleaned == "" ||
 			cleaned == "WEBVTT" ||
 			strings.HasPrefix(cleaned, "Kind:") ||
@@ -38,28 +100,86 @@ func VTTToText(vttContent string) string {
 			isNumeric(cleaned) {
 			continue
 		}
+		lines = append(lines, Segment{Start: cueStart, Text: cleaned})
+	}
 
-		lines = append(lines, cleaned)
+	if dedup == DedupAuto || dedup == "" {
+		dedup = DedupNone
+		if wordTimed {
+			dedup = DedupRolling
+		}
+	}
+	if dedup == DedupNone {
+		return lines
+	}
+	same := strings.HasPrefix
+	if dedup == DedupFuzzy {
+		same = func(next, line string) bool { return strings.HasPrefix(fuzzyKey(next), fuzzyKey(line)) }
 	}
 
-	// Deduplicate rolling prefixes:
-	// If lines[i] is a prefix of lines[i+1], skip lines[i].
-	var deduped []string
+	// Drop each line the next one repeats, carrying the start of the first
+	// line in each chain onto the line that survives.
+	var deduped []Segment
+	chainStart := time.Duration(-1)
 	for i, line := range lines {
-		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], line) {
+		if chainStart < 0 {
+			chainStart = line.Start
+		}
+		if i+1 < len(lines) && same(lines[i+1].Text, line.Text) {
 			continue
 		}
-		deduped = append(deduped, line)
+		deduped = append(deduped, Segment{Start: chainStart, Text: line.Text})
+		chainStart = -1
 	}
+	return deduped
+}
 
-	return strings.Join(deduped, " ")
+// fuzzyKey lowercases s and drops everything but letters and digits.
+func fuzzyKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
 }
 
-func isNumeric(s string) bool {
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return false
+func parseCueTime(m []string) time.Duration {
+	atoi := func(s string) time.Duration {
+		n, _ := strconv.Atoi(s)
+		return time.Duration(n)
+	}
+	ms := m[4]
+	for len(ms) < 3 {
+		ms += "0"
+	}
+	return atoi(m[1])*time.Hour + atoi(m[2])*time.Minute + atoi(m[3])*time.Second + atoi(ms[:3])*time.Millisecond
+}
+
+// Timecoded renders segments as lines prefixed with "[m:ss]" (or "[h:mm:ss]"),
+// starting a new line only once at least every has passed since the last
+// timecode, so prompts can cite moments without a marker per cue.
+func Timecoded(segments []Segment, every time.Duration) string {
+	var sb strings.Builder
+	last := time.Duration(-1)
+	for _, seg := range segments {
+		if last < 0 || seg.Start-last >= every {
+			if last >= 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString("[" + FormatTimecode(seg.Start) + "]")
+			last = seg.Start
 		}
+		sb.WriteString(" " + seg.Text)
 	}
-	return len(s) > 0
+	return sb.String()
+}
+
+// FormatTimecode formats d as m:ss, or h:mm:ss from one hour.
+func FormatTimecode(d time.Duration) string {
+	s := int(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
 }
@@ -0,0 +1,98 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rollingVTT mimics YouTube auto-captions: each cue repeats the previous
+// line before adding new words with inline timing tags.
+const rollingVTT = `WEBVTT
+Kind: captions
+Language: en
+
+00:00:01.000 --> 00:00:03.000 align:start position:0%
+welcome<00:00:01.500><c> to</c><00:00:02.000><c> the</c><00:00:02.500><c> lab</c>
+
+00:00:03.000 --> 00:00:03.010 align:start position:0%
+welcome to the lab
+
+00:00:03.010 --> 00:00:05.000 align:start position:0%
+welcome to the lab
+today<00:00:03.500><c> we</c><00:00:04.000><c> harden</c><00:00:04.500><c> images</c>
+
+00:00:35.000 --> 00:00:37.000 align:start position:0%
+now<00:00:35.500><c> the</c><00:00:36.000><c> auth</c><00:00:36.500><c> step</c>
+`
+
+func TestVTTToSegmentsMatchesVTTToText(t *testing.T) {
+	segs := VTTToSegments(rollingVTT, DedupAuto)
+
+	var texts []string
+	for _, s := range segs {
+		texts = append(texts, s.Text)
+	}
+	if got, want := strings.Join(texts, " "), VTTToText(rollingVTT, DedupAuto); got != want {
+		t.Errorf("joined segments = %q, want VTTToText output %q", got, want)
+	}
+
+	want := []Segment{
+		{Start: 1 * time.Second, Text: "welcome to the lab"},
+		{Start: 3010 * time.Millisecond, Text: "today we harden images"},
+		{Start: 35 * time.Second, Text: "now the auth step"},
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("got %d segments %+v, want %d", len(segs), segs, len(want))
+	}
+	for i := range want {
+		if segs[i] != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, segs[i], want[i])
+		}
+	}
+}
+
+// uploadedVTT mimics manually uploaded captions: no word timing, and two
+// consecutive lines where the first is a prefix of the second.
+const uploadedVTT = `WEBVTT
+Kind: captions
+Language: en
+
+1
+00:00:01.000 --> 00:00:02.000
+Run the scan.
+
+2
+00:00:02.000 --> 00:00:04.000
+Run the scan. Then run it again.
+`
+
+func TestVTTToTextDedupStrategies(t *testing.T) {
+	both := "Run the scan. Run the scan. Then run it again."
+	for _, tc := range []struct {
+		vtt   string
+		dedup Dedup
+		want  string
+	}{
+		{uploadedVTT, DedupAuto, both},
+		{uploadedVTT, DedupNone, both},
+		{uploadedVTT, DedupRolling, "Run the scan. Then run it again."},
+		{rollingVTT, DedupNone, "welcome to the lab welcome to the lab welcome to the lab today we harden images now the auth step"},
+		{"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nWelcome, to the LAB\n\n00:00:02.000 --> 00:00:03.000\nwelcome to the lab today\n", DedupFuzzy, "welcome to the lab today"},
+	} {
+		if got := VTTToText(tc.vtt, tc.dedup); got != tc.want {
+			t.Errorf("VTTToText(%s) = %q, want %q", tc.dedup, got, tc.want)
+		}
+	}
+}
+
+func TestTimecoded(t *testing.T) {
+	got := Timecoded(VTTToSegments(rollingVTT, DedupAuto), 30*time.Second)
+	want := "[0:01] welcome to the lab today we harden images\n[0:35] now the auth step"
+	if got != want {
+		t.Errorf("Timecoded = %q, want %q", got, want)
+	}
+	if got := FormatTimecode(time.Hour + 2*time.Minute + 3*time.Second); got != "1:02:03" {
+		t.Errorf("FormatTimecode(1h2m3s) = %q", got)
+	}
+}
@@ -2,28 +2,169 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"llgen/data"
+	"llgen/internal/atomicfile"
+	"llgen/internal/catalogdiff"
 	"llgen/internal/claude"
 	"llgen/internal/collect"
 	"llgen/internal/config"
+	"llgen/internal/embed"
+	"llgen/internal/events"
 	"llgen/internal/generate"
+	"llgen/internal/logging"
+	"llgen/internal/pool"
+	"llgen/internal/progress"
+	"llgen/internal/query"
+	"llgen/internal/report"
+	"llgen/internal/runlock"
 	"llgen/internal/transform"
 )
 
+// issues collects non-fatal problems for the end-of-run summary and
+// run-report.md; runReportPath and runReportMode are set once the output
+// directory is known.
+var (
+	issues        = report.New()
+	runReportPath string
+	runReportMode = os.FileMode(0o644)
+	releaseLock   = func() {} // drops the cache-dir run lock; see runlock
+	flushUsage    = func() {} // writes usage-report.json once the output directory exists
+)
+
 func main() {
 	cfg := config.Parse()
+	// The lab map is hand-maintained; a copy-pasted ID or VideoID would
+	// silently mix two labs' caches.
+	if err := data.Validate(data.Labs); err != nil {
+		log.Fatal(err)
+	}
+	runReportPath = filepath.Join(cfg.OutputDir, "run-report.md")
+	runReportMode = cfg.FileMode
+	switch {
+	case cfg.Verbose:
+		logging.SetLevel(logging.Verbose)
+	case cfg.Quiet:
+		logging.SetLevel(logging.Quiet)
+	}
 
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		log.Fatal("ANTHROPIC_API_KEY environment variable is required")
+	if cfg.Command == "diff-catalog" {
+		runDiffCatalog(cfg)
+		return
+	}
+	if cfg.Command == "check-videos" {
+		runCheckVideos(cfg)
+		return
 	}
 
-	ctx := context.Background()
+	var apiKey string
+	if cfg.Provider == "openai" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" && cfg.ProviderURL == claude.DefaultOpenAIURL && cfg.UsesLLM() {
+			log.Fatal("OPENAI_API_KEY environment variable is required for the OpenAI API")
+		}
+	} else {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" && cfg.UsesLLM() {
+			log.Fatal("ANTHROPIC_API_KEY environment variable is required")
+		}
+	}
+
+	// One transport, honoring -proxy, carries every outbound request: the
+	// collectors' and the generation and embedding APIs'.
+	transport, err := collect.NewTransport(cfg.Proxy)
+	if err != nil {
+		log.Fatalf("-proxy: %v", err)
+	}
+	collect.UseTransport(transport)
+
+	// newClient builds a generator for model on the configured backend. All
+	// clients share one rate limiter.
+	limiter := claude.NewLimiter(cfg.ClaudeRPM)
+	newClient := func(model string) backend {
+		if cfg.Provider == "openai" {
+			c := claude.NewOpenAIClient(cfg.ProviderURL, apiKey, model, transport)
+			c.SetLimiter(limiter)
+			return c
+		}
+		c := claude.NewClient(apiKey, model, transport)
+		c.SetLimiter(limiter)
+		if cfg.SaveThinking != "" {
+			c.SaveThinking(cfg.SaveThinking)
+		}
+		return c
+	}
+
+	pricing, err := claude.LoadPricing(cfg.PricingFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	usage := claude.NewTracker()
+	ctx := claude.WithTracker(context.Background(), usage)
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	if cfg.Command == "query" {
+		runQuery(ctx, newClient(cfg.Model), cfg)
+		return
+	}
+	if cfg.Command == "compare" {
+		runCompare(ctx, newClient(cfg.Model), cfg)
+		return
+	}
+
+	// Fail fast on a bad key or inaccessible model before minutes of
+	// collection work, checking each distinct model the run will use.
+	if !cfg.SkipPreflight && cfg.UsesLLM() {
+		fmt.Printf("==> Checking %s API access...\n", cfg.Provider)
+		checked := map[string]bool{}
+		for _, name := range config.OutputFiles {
+			model := cfg.ModelForOutput(name)
+			if !cfg.Selected(name) || !config.UsesGenerator(name) || checked[model] {
+				continue
+			}
+			checked[model] = true
+			if err := newClient(model).Preflight(ctx); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	// -include-unpublished=false drops unpublished labs from the lab map
+	// itself, so every generator, including the indexes that list all
+	// labs, sees only published ones.
+	if !cfg.IncludeUnpublished {
+		var dropped []string
+		data.Labs = slices.DeleteFunc(slices.Clone(data.Labs), func(l data.LabMeta) bool {
+			if !l.Published() {
+				dropped = append(dropped, l.ID)
+				return true
+			}
+			return false
+		})
+		if len(dropped) > 0 {
+			fmt.Printf("==> Leaving out %d unpublished labs: %s\n", len(dropped), strings.Join(dropped, ", "))
+		}
+		if slices.Contains(dropped, cfg.Lab) {
+			log.Fatalf("lab %q is unpublished; drop -include-unpublished=false to generate it", cfg.Lab)
+		}
+	}
 
 	// Determine which labs to process.
 	// --lab implies --force for the cache dirs of that lab.
@@ -40,8 +181,61 @@ func main() {
 		if !found {
 			log.Fatalf("lab %q not found in lab map", cfg.Lab)
 		}
-		// --lab implies force for that single lab's intermediates
-		cfg.Force = true
+		// --lab implies force for that single lab's intermediates, in both
+		// phases unless -force-collect or -force-generate narrows it.
+		if !cfg.ForceCollect && !cfg.ForceGenerate {
+			cfg.Force = true
+		}
+	}
+
+	// --since-lab keeps every lab in the run (so assembly sees the full set
+	// from cache) but forces only the selected ones. IDs are llYYYYMM, so
+	// lexical order is chronological.
+	if cfg.SinceLab != "" {
+		if cfg.Lab != "" {
+			log.Fatal("--lab and --since-lab are mutually exclusive")
+		}
+		for _, l := range data.Labs {
+			if l.ID >= cfg.SinceLab {
+				cfg.ForceLabs[l.ID] = true
+			}
+		}
+		if len(cfg.ForceLabs) == 0 {
+			log.Fatalf("no labs with ID >= %q in lab map", cfg.SinceLab)
+		}
+		fmt.Printf("==> Regenerating %d labs since %s\n", len(cfg.ForceLabs), cfg.SinceLab)
+	}
+
+	if cfg.EventsFile != "" {
+		if err := events.Open(cfg.EventsFile); err != nil {
+			log.Fatal(err)
+		}
+		defer events.Close()
+	}
+
+	// --sample picks N labs at random, keeping lab map order, and forces
+	// them like --lab does. Its outputs go to a sample/ subdirectory so the
+	// real catalog, and the embeddings and recommender built from it, are
+	// never replaced by an N-lab one.
+	if cfg.Sample > 0 {
+		cfg.OutputDir = filepath.Join(cfg.OutputDir, "sample")
+		runReportPath = filepath.Join(cfg.OutputDir, "run-report.md")
+		if cfg.Seed == 0 {
+			cfg.Seed = time.Now().UnixNano()
+		}
+		labs = sampleLabs(data.Labs, cfg.Sample, cfg.Seed)
+		ids := make([]string, len(labs))
+		for i, l := range labs {
+			ids[i] = l.ID
+			cfg.ForceLabs[l.ID] = true
+		}
+		fmt.Printf("==> Sampling %d of %d labs (-seed %d) into %s: %s\n", len(labs), len(data.Labs), cfg.Seed, cfg.OutputDir, strings.Join(ids, ", "))
+		issues.Addf("", "partial run: -sample %d -seed %d processed only %s", cfg.Sample, cfg.Seed, strings.Join(ids, ", "))
+	}
+
+	if cfg.Plan {
+		printPlan(generate.Plan(cfg, labs))
+		return
 	}
 
 	// Ensure required directories exist.
@@ -56,82 +250,562 @@ func main() {
 			log.Fatalf("mkdir %s: %v", dir, err)
 		}
 	}
+	// A failed or timed-out run still reports what it spent.
+	flushUsage = func() {
+		if err := writeUsageReport(cfg, usage, pricing); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// One run per cache dir: a second one (say a -lab run during a full
+	// run) would race on the same per-lab cache files.
+	release, err := runlock.Acquire(cfg.CacheDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	releaseLock = release
+	defer release()
+
+	// A wrong -decks-dir would otherwise show up only as empty deck text in
+	// every lab.
+	if cfg.DecksDir != "" {
+		logging.Infof("==> Decks directory: %s\n", cfg.DecksDir)
+	}
+	if fi, err := os.Stat(cfg.DecksDir); cfg.DecksDir != "" && (err != nil || !fi.IsDir()) {
+		msg := fmt.Sprintf("decks-dir %s not found; all deck text will be empty", cfg.DecksDir)
+		if cfg.PDFDecks {
+			msg = fmt.Sprintf("decks-dir %s not found; deck text will come only from public PDFs", cfg.DecksDir)
+		}
+		log.Printf("Warning: %s (set -decks-dir)", msg)
+		issues.Addf("", "%s", msg)
+	}
 
 	// Phase 1: Collect playlist metadata (best-effort; used for titles/dates).
 	fmt.Println("==> Fetching playlist metadata...")
-	playlistInfo, err := collect.FetchPlaylistInfo(cfg)
+	endPhase := events.Phase("playlist")
+	playlistInfo, err := collect.FetchPlaylistInfo(ctx, cfg)
+	if cfg.Offline && err != nil {
+		fatal("fetch playlist info", err)
+	}
 	if err != nil {
 		log.Printf("Warning: could not fetch playlist info: %v", err)
+		issues.Addf("", "playlist metadata unavailable (titles/dates will be missing): %v", err)
 		playlistInfo = map[string]collect.VideoInfo{}
 	}
+	endPhase()
 
 	// Phase 1: Download transcripts.
 	fmt.Println("==> Downloading transcripts...")
-	for _, lab := range labs {
-		if err := collect.DownloadTranscript(cfg, lab); err != nil {
-			log.Printf("Warning: transcript %s (%s): %v", lab.ID, lab.VideoID, err)
+	endPhase = events.Phase("transcripts")
+	// Cached transcripts return at once; only cold ones spawn yt-dlp, a
+	// few at a time.
+	bar := progress.New("transcripts", len(labs))
+	downloadErrs := make([]error, len(labs))
+	pool.ForEach(ctx, cfg.DownloadWorkers, len(labs), func(i int) {
+		lab := labs[i]
+		if err := collect.DownloadTranscript(ctx, cfg, lab); err != nil {
+			downloadErrs[i] = err
+			if !errors.Is(err, collect.ErrOffline) {
+				bar.Printf("Warning: transcript %s (%s): %v\n", lab.ID, lab.VideoID, err)
+				issues.Addf(lab.ID, "transcript download (%s): %v", lab.VideoID, err)
+			}
+		}
+		bar.Step(lab.ID)
+	})
+	bar.Done()
+	for i, err := range downloadErrs {
+		if errors.Is(err, collect.ErrOffline) {
+			fatal("download transcript "+labs[i].ID, err)
 		}
 	}
+	endPhase()
 
 	// Phase 1: Fetch GitHub guides.
 	fmt.Println("==> Fetching GitHub guides...")
+	endPhase = events.Phase("guides")
+	// Fetched concurrently into the cache, once per guide; BuildCorpus
+	// only reads the cache. Each guide's cache file is written atomically.
+	var guideLabs []data.LabMeta
+	seenGuides := make(map[string]bool)
 	for _, lab := range labs {
-		if lab.GitHubID == "" {
-			continue
+		if lab.GitHubID != "" && !seenGuides[lab.GitHubID] {
+			seenGuides[lab.GitHubID] = true
+			guideLabs = append(guideLabs, lab)
 		}
-		if _, err := collect.FetchGitHubGuide(cfg, lab.GitHubID); err != nil {
-			log.Printf("Warning: GitHub guide %s: %v", lab.GitHubID, err)
+	}
+	guideErrs := make([]error, len(guideLabs))
+	bar = progress.New("guides", len(guideLabs))
+	pool.ForEach(ctx, cfg.Concurrency, len(guideLabs), func(i int) {
+		lab := guideLabs[i]
+		if _, err := collect.FetchGitHubGuide(ctx, cfg, lab.GitHubID); err != nil {
+			guideErrs[i] = err
+			if !errors.Is(err, collect.ErrOffline) {
+				bar.Printf("Warning: GitHub guide %s: %v\n", lab.GitHubID, err)
+				issues.Addf(lab.ID, "GitHub guide fetch: %v", err)
+			}
+		}
+		bar.Step(lab.ID)
+	})
+	bar.Done()
+	for i, err := range guideErrs {
+		if errors.Is(err, collect.ErrOffline) {
+			fatal("fetch GitHub guide "+guideLabs[i].ID, err)
 		}
 	}
+	endPhase()
 
 	// Phase 2: Build corpora (transcript + guide + deck per lab).
 	fmt.Println("==> Building lab corpora...")
+	endPhase = events.Phase("corpora")
+	shared := transform.SharedDecks(data.Labs)
+	decks := make([]string, 0, len(shared))
+	for deck := range shared {
+		decks = append(decks, deck)
+	}
+	sort.Strings(decks)
+	for _, deck := range decks {
+		logging.Infof("  shared deck %q used by %s; its text is marked as a template in catalog prompts\n", deck, strings.Join(shared[deck], ", "))
+	}
 	corpora := make(map[string]*transform.LabCorpus)
-	for _, lab := range labs {
-		corpus, err := transform.BuildCorpus(cfg, lab)
+	var corporaMu sync.Mutex
+	bar = progress.New("corpora", len(labs))
+	pool.ForEach(ctx, cfg.Concurrency, len(labs), func(i int) {
+		lab := labs[i]
+		corpus, err := transform.BuildCorpus(ctx, cfg, lab, playlistInfo[lab.VideoID])
 		if err != nil {
-			log.Printf("Warning: corpus build %s: %v", lab.ID, err)
-			corpus = &transform.LabCorpus{Lab: lab}
+			bar.Printf("Warning: corpus build %s: %v\n", lab.ID, err)
+			issues.Addf(lab.ID, "corpus build: %v", err)
+			corpus = &transform.LabCorpus{Lab: lab, Title: lab.ID, TitleSource: "lab ID"}
+		}
+		for _, w := range corpus.Warnings {
+			bar.Printf("Warning: %s: %s\n", lab.ID, w)
+			issues.Addf(lab.ID, "%s", w)
+		}
+		// Report sources the lab map says exist but that didn't load.
+		if !corpus.HasSource("transcript") || (lab.GitHubID != "" && !corpus.HasSource("guide")) || (lab.DeckFile != "" && !corpus.HasSource("deck")) {
+			issues.Addf(lab.ID, "corpus built from %s", corpus.SourceSummary())
 		}
-		// Populate title/date from playlist metadata
-		if info, ok := playlistInfo[lab.VideoID]; ok {
-			corpus.Title = info.Title
-			corpus.UploadDate = info.UploadDate
+		if corpus.HasSource("guide") && len(transform.RunnableCommands(corpus.CodeBlocks)) == 0 {
+			issues.Addf(lab.ID, "GitHub guide has no runnable shell commands (%d code blocks)", len(corpus.CodeBlocks))
 		}
+		logging.Debugf("  corpus %s: %s\n", lab.ID, corpus.SourceSummary())
+		events.Emit(events.Event{Kind: events.LabCollected, Lab: lab.ID, Detail: corpus.SourceSummary()})
+		corporaMu.Lock()
 		corpora[lab.ID] = corpus
+		corporaMu.Unlock()
+		bar.Step(lab.ID)
+	})
+	bar.Done()
+	endPhase()
+
+	for _, lab := range labs {
+		corpus, ok := corpora[lab.ID]
+		if !ok {
+			// Not built (run deadline hit); keep an empty corpus so later
+			// phases fail on the deadline rather than on a nil lookup.
+			corpora[lab.ID] = &transform.LabCorpus{Lab: lab, Title: lab.ID, TitleSource: "lab ID"}
+			continue
+		}
+		// The index generators read titles from playlistInfo; give them
+		// the corpus's fallback title rather than a blank cell.
+		if info := playlistInfo[lab.VideoID]; info.Title == "" {
+			info.Title = corpus.Title
+			playlistInfo[lab.VideoID] = info
+		}
+	}
+
+	if cfg.DumpCorpus && !cfg.NoLLM {
+		fmt.Println("==> Dumping lab corpora...")
+		if err := generate.DumpCorpora(cfg, labs, corpora); err != nil {
+			fatal("dump corpora", err)
+		}
+	}
+
+	if cfg.CorpusStats {
+		printCorpusStats(labs, corpora)
+		finishReport()
+		return
+	}
+
+	if cfg.NoLLM {
+		runNoLLM(cfg, labs, corpora, playlistInfo)
+		finishReport()
+		fmt.Println("==> Done.")
+		return
 	}
 
 	// Phase 3: Generate output files in dependency order.
-	claudeClient := claude.NewClient(apiKey, cfg.Model)
+	// Clients are shared between outputs that resolve to the same model.
+	clients := make(map[string]backend)
+	clientFor := func(output string) backend {
+		model := cfg.ModelForOutput(output)
+		if c, ok := clients[model]; ok {
+			return c
+		}
+		c := newClient(model)
+		clients[model] = c
+		return c
+	}
+
+	// Outputs run in config.OutputFiles (dependency) order regardless of the
+	// order given to --only.
+	runAll := len(cfg.Only) == 0
 
-	only := cfg.Only
-	runAll := only == ""
+	// Embeddings need a separate provider key; a full run skips them when it
+	// is absent rather than failing after the catalog is already written.
+	voyageKey := os.Getenv("VOYAGE_API_KEY")
+	wantEmbeddings := !runAll && cfg.Selected("labs-embeddings.json")
+	if wantEmbeddings && voyageKey == "" {
+		fatal("generate embeddings", errors.New("VOYAGE_API_KEY environment variable is required for labs-embeddings.json"))
+	}
+
+	// written lists the outputs this run generated, for the manifest.
+	var written []string
+
+	if cfg.Thumbnails && cfg.Selected("learning-labs-index.md") {
+		fmt.Println("==> Fetching video thumbnails...")
+		endPhase = events.Phase("thumbnails")
+		// Cosmetic: a missing thumbnail gets a dash in the table, so
+		// failures are reported but never fatal.
+		bar := progress.New("thumbnails", len(data.Labs))
+		pool.ForEach(ctx, cfg.Concurrency, len(data.Labs), func(i int) {
+			lab := data.Labs[i]
+			if _, err := collect.FetchThumbnail(ctx, cfg, lab); err != nil && !errors.Is(err, collect.ErrOffline) {
+				bar.Printf("Warning: thumbnail %s (%s): %v\n", lab.ID, lab.VideoID, err)
+				issues.Addf(lab.ID, "thumbnail download (%s): %v", lab.VideoID, err)
+			}
+			bar.Step(lab.ID)
+		})
+		bar.Done()
+		endPhase()
+	}
 
-	if runAll || only == "learning-labs-index.md" {
+	if cfg.Selected("learning-labs-index.md") {
 		fmt.Println("==> Generating learning-labs-index.md...")
-		if err := generate.Index(ctx, claudeClient, cfg, data.Labs, playlistInfo); err != nil {
-			log.Fatalf("generate index: %v", err)
+		endPhase = events.Phase("learning-labs-index.md")
+		if err := generate.Index(ctx, clientFor("learning-labs-index.md"), cfg, data.Labs, playlistInfo); err != nil {
+			fatal("generate index", err)
 		}
+		endPhase()
+		written = append(written, "learning-labs-index.md")
 	}
 
-	if runAll || only == "labs-catalog.json" {
+	if cfg.Selected("learning-labs-index.json") {
+		fmt.Println("==> Generating learning-labs-index.json...")
+		endPhase = events.Phase("learning-labs-index.json")
+		if err := generate.IndexJSON(cfg, data.Labs, playlistInfo); err != nil {
+			fatal("generate index json", err)
+		}
+		endPhase()
+		written = append(written, "learning-labs-index.json")
+	}
+
+	if cfg.Selected("labs-catalog.json") {
 		fmt.Println("==> Generating labs-catalog.json...")
-		if err := generate.Catalog(ctx, claudeClient, cfg, labs, corpora); err != nil {
-			log.Fatalf("generate catalog: %v", err)
+		endPhase = events.Phase("labs-catalog.json")
+		if err := generate.Catalog(ctx, clientFor("labs-catalog.json"), cfg, labs, corpora); err != nil {
+			fatal("generate catalog", err)
+		}
+		endPhase()
+		written = append(written, "labs-catalog.json")
+	}
+
+	if (runAll && voyageKey != "") || wantEmbeddings {
+		fmt.Println("==> Generating labs-embeddings.json...")
+		endPhase = events.Phase("labs-embeddings.json")
+		embedder := embed.NewVoyageClient(voyageKey, cfg.EmbedModel, transport)
+		if err := generate.Embeddings(ctx, embedder, cfg); err != nil {
+			fatal("generate embeddings", err)
 		}
+		endPhase()
+		written = append(written, "labs-embeddings.json")
+	} else if runAll {
+		fmt.Println("==> Skipping labs-embeddings.json (VOYAGE_API_KEY not set)")
 	}
 
-	if runAll || only == "recommender-system-prompt.md" {
+	if cfg.Selected("recommender-system-prompt.md") {
 		// Requires labs-catalog.json to exist
 		catalogPath := filepath.Join(cfg.OutputDir, "labs-catalog.json")
 		if _, err := os.Stat(catalogPath); os.IsNotExist(err) {
-			log.Fatalf("recommender requires labs-catalog.json; run catalog generation first or use --only labs-catalog.json")
+			fatal("generate recommender", errors.New("labs-catalog.json is required; run catalog generation first or use --only labs-catalog.json"))
 		}
 		fmt.Println("==> Generating recommender-system-prompt.md...")
-		if err := generate.Recommender(ctx, claudeClient, cfg); err != nil {
-			log.Fatalf("generate recommender: %v", err)
+		endPhase = events.Phase("recommender-system-prompt.md")
+		if err := generate.Recommender(ctx, clientFor("recommender-system-prompt.md"), cfg); err != nil {
+			fatal("generate recommender", err)
 		}
+		endPhase()
+		written = append(written, "recommender-system-prompt.md")
 	}
 
+	if err := generate.WriteManifest(cfg, written, corpora); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	flushUsage()
+	finishReport()
+
 	fmt.Println("==> Done.")
 }
+
+// sampleLabs returns n labs chosen from all by seed, in their original order.
+func sampleLabs(all []data.LabMeta, n int, seed int64) []data.LabMeta {
+	if n >= len(all) {
+		return all
+	}
+	picked := rand.New(rand.NewSource(seed)).Perm(len(all))[:n]
+	sort.Ints(picked)
+	labs := make([]data.LabMeta, n)
+	for i, idx := range picked {
+		labs[i] = all[idx]
+	}
+	return labs
+}
+
+// writeUsageReport writes usage-report.json summarizing token usage and
+// estimated cost per output file, lab, and model.
+func writeUsageReport(cfg *config.Config, usage *claude.Tracker, pricing map[string]claude.Price) error {
+	report := usage.Report(pricing)
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal usage report: %w", err)
+	}
+	outPath := filepath.Join(cfg.OutputDir, "usage-report.json")
+	if err := atomicfile.WriteFile(outPath, out, cfg.FileMode); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	fmt.Printf("==> Usage: %d calls, %d input / %d output tokens, ~$%.2f (%s)\n",
+		report.Total.Calls, report.Total.InputTokens, report.Total.OutputTokens, report.Total.CostUSD, outPath)
+	return nil
+}
+
+// fatal reports a phase failure and exits. A -timeout overrun is reported as
+// such rather than as an opaque API error; per-lab caches completed before the
+// deadline are already on disk, so a re-run resumes from them. It is for the
+// generation pipeline only: it rewrites run-report.md, which a failed
+// subcommand must leave alone, so subcommands use log.Fatal.
+func fatal(what string, err error) {
+	events.Fail("", fmt.Errorf("%s: %w", what, err))
+	events.Close()
+	releaseLock()
+	flushUsage()
+	finishReport()
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Fatalf("%s: run exceeded -timeout; completed per-lab caches were saved, re-run to resume", what)
+	}
+	log.Fatalf("%s: %v", what, err)
+}
+
+// runNoLLM writes the artifacts that need no Claude calls: the JSON index,
+// the data-driven index table, and a dump of each lab's corpus, so the input
+// to generation can be inspected before paying for it.
+func runNoLLM(cfg *config.Config, labs []data.LabMeta, corpora map[string]*transform.LabCorpus, playlistInfo map[string]collect.VideoInfo) {
+	if cfg.Selected("learning-labs-index.json") {
+		fmt.Println("==> Generating learning-labs-index.json...")
+		if err := generate.IndexJSON(cfg, data.Labs, playlistInfo); err != nil {
+			fatal("generate index json", err)
+		}
+	}
+
+	fmt.Println("==> Writing index table and corpus dumps (-no-llm)...")
+	if err := generate.IndexTable(cfg, data.Labs, playlistInfo); err != nil {
+		fatal("write index table", err)
+	}
+	if err := generate.DumpCorpora(cfg, labs, corpora); err != nil {
+		fatal("dump corpora", err)
+	}
+}
+
+// printCorpusStats prints each lab's estimated corpus tokens by source,
+// largest first, with totals, to show which labs dominate context and cost.
+func printCorpusStats(labs []data.LabMeta, corpora map[string]*transform.LabCorpus) {
+	type row struct {
+		id                                string
+		transcript, guide, deck, combined int
+	}
+	var rows []row
+	var total row
+	for _, lab := range labs {
+		c := corpora[lab.ID]
+		r := row{
+			id:         lab.ID,
+			transcript: transform.EstimateTokens(c.Transcript),
+			guide:      transform.EstimateTokens(c.GitHubGuide),
+			deck:       transform.EstimateTokens(c.DeckText),
+		}
+		r.combined = r.transcript + r.guide + r.deck
+		rows = append(rows, r)
+		total.transcript += r.transcript
+		total.guide += r.guide
+		total.deck += r.deck
+		total.combined += r.combined
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].combined > rows[j].combined })
+
+	fmt.Println("==> Estimated corpus tokens (words × 1.3):")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "lab\ttranscript\tguide\tdeck\ttotal\tshare\t")
+	for _, r := range rows {
+		share := 0.0
+		if total.combined > 0 {
+			share = 100 * float64(r.combined) / float64(total.combined)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.0f%%\t\n", r.id, r.transcript, r.guide, r.deck, r.combined, share)
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t%d\t%d\t\t\n", total.transcript, total.guide, total.deck, total.combined)
+	w.Flush()
+}
+
+// printPlan prints the -plan table and a count of cache files per action.
+func printPlan(items []generate.PlanItem) {
+	fmt.Println("==> Cache plan (nothing is fetched or generated):")
+	counts := map[string]int{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "output\tlab\taction\texists\tpath\tnote")
+	for _, it := range items {
+		counts[it.Action]++
+		lab := it.Lab
+		if lab == "" {
+			lab = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\n", it.Output, lab, it.Action, it.Exists, it.Path, it.Note)
+	}
+	w.Flush()
+	fmt.Printf("%d reuse, %d regenerate, %d generate\n", counts[generate.PlanReuse], counts[generate.PlanRegenerate], counts[generate.PlanGenerate])
+}
+
+// backend is a generator that can also check its credentials up front;
+// both *claude.Client and *claude.OpenAIClient are.
+type backend interface {
+	claude.Generator
+	Preflight(ctx context.Context) error
+}
+
+// runQuery implements the "query" subcommand: it sends the positional text
+// through the generated recommender and prints the answer.
+func runQuery(ctx context.Context, client claude.Generator, cfg *config.Config) {
+	question := strings.TrimSpace(strings.Join(cfg.Args, " "))
+	if question == "" {
+		log.Fatal(`usage: llgen query [flags] "I want zero-CVE containers"`)
+	}
+	answer, err := query.Ask(ctx, client, cfg, question)
+	if err != nil {
+		log.Fatalf("query: %v", err)
+	}
+	fmt.Println(answer)
+}
+
+// runCompare implements the "compare" subcommand: it builds both labs'
+// corpora, downloading any transcript not yet cached, and writes the
+// generated comparison.
+func runCompare(ctx context.Context, client claude.Generator, cfg *config.Config) {
+	if len(cfg.Args) != 2 || cfg.Args[0] == cfg.Args[1] {
+		log.Fatal("usage: llgen compare [flags] ll202509 ll202512")
+	}
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		log.Fatalf("mkdir %s: %v", cfg.OutputDir, err)
+	}
+	var corpora []*transform.LabCorpus
+	for _, id := range cfg.Args {
+		i := slices.IndexFunc(data.Labs, func(l data.LabMeta) bool { return l.ID == id })
+		if i < 0 {
+			log.Fatalf("lab %q not found in lab map", id)
+		}
+		lab := data.Labs[i]
+		if err := collect.DownloadTranscript(ctx, cfg, lab); err != nil {
+			log.Printf("Warning: transcript %s (%s): %v", lab.ID, lab.VideoID, err)
+		}
+		if lab.GitHubID != "" {
+			if _, err := collect.FetchGitHubGuide(ctx, cfg, lab.GitHubID); err != nil {
+				log.Printf("Warning: GitHub guide %s: %v", lab.GitHubID, err)
+			}
+		}
+		corpus, err := transform.BuildCorpus(ctx, cfg, lab, collect.VideoInfo{})
+		if err != nil {
+			log.Fatalf("compare: %v", err)
+		}
+		for _, w := range corpus.Warnings {
+			log.Printf("Warning: %s: %s", lab.ID, w)
+		}
+		corpora = append(corpora, corpus)
+	}
+	fmt.Printf("==> Comparing %s and %s...\n", cfg.Args[0], cfg.Args[1])
+	if err := generate.Compare(ctx, client, cfg, corpora[0], corpora[1]); err != nil {
+		log.Fatalf("compare: %v", err)
+	}
+}
+
+// finishReport prints the consolidated issue summary and writes run-report.md.
+func finishReport() {
+	issues.Print(os.Stdout)
+	if err := issues.WriteMarkdown(runReportPath, runReportMode); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func runDiffCatalog(cfg *config.Config) {
+	if len(cfg.Args) != 2 {
+		log.Fatal("usage: llgen diff-catalog old-labs-catalog.json new-labs-catalog.json")
+	}
+	oldPath, newPath := cfg.Args[0], cfg.Args[1]
+	oldJSON, err := os.ReadFile(oldPath)
+	if err != nil {
+		log.Fatalf("diff-catalog: %v", err)
+	}
+	newJSON, err := os.ReadFile(newPath)
+	if err != nil {
+		log.Fatalf("diff-catalog: %v", err)
+	}
+	d, err := catalogdiff.Compare(oldJSON, newJSON)
+	if err != nil {
+		log.Fatalf("diff-catalog: %v", err)
+	}
+	fmt.Print(d.Markdown(oldPath, newPath))
+}
+
+// runCheckVideos probes every lab's video (or just -lab's) with yt-dlp and
+// exits non-zero if any fails to resolve, so labmap drift is caught before
+// a full collect.
+func runCheckVideos(cfg *config.Config) {
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+	labs := data.Labs
+	if cfg.Lab != "" {
+		labs = nil
+		for _, l := range data.Labs {
+			if l.ID == cfg.Lab {
+				labs = append(labs, l)
+			}
+		}
+		if len(labs) == 0 {
+			log.Fatalf("lab %q not found in lab map", cfg.Lab)
+		}
+	}
+
+	results := make([]collect.ProbeResult, len(labs))
+	bar := progress.New("videos", len(labs))
+	pool.ForEach(ctx, cfg.Concurrency, len(labs), func(i int) {
+		results[i] = collect.ProbeVideo(ctx, cfg, labs[i].VideoID)
+		bar.Step(labs[i].ID)
+	})
+	bar.Done()
+
+	failed := 0
+	for i, lab := range labs {
+		r := results[i]
+		if r.Problem == "" {
+			fmt.Printf("  ok    %s  %s  %s\n", lab.ID, lab.VideoID, r.Title)
+			continue
+		}
+		failed++
+		fmt.Printf("  FAIL  %s  %s  %s: %s\n", lab.ID, lab.VideoID, r.Problem, r.Detail)
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d videos did not resolve; update data/labmap.go\n", failed, len(labs))
+		os.Exit(1)
+	}
+	fmt.Printf("All %d videos resolve.\n", len(labs))
+}
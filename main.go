@@ -1,336 +1,91 @@
+// Command unchained-scraper archives the Chainguard Unchained blog to
+// output/unchained-archive.md, scraping only posts missing from the
+// checkpoint on incremental runs. The crawling itself lives in package scraper.
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
-	"regexp"
-	"strings"
-	"sync"
-	"sync/atomic"
+	"slices"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
-	md "github.com/JohannesKaufmann/html-to-markdown"
+	"unchained-scraper/scraper"
 )
 
 const (
-	baseURL        = "https://chainguard.dev"
-	unchainedURL   = baseURL + "/unchained"
 	outputDir      = "output"
-	archivePath    = outputDir + "/unchained-archive.md"
+	archiveBase    = outputDir + "/unchained-archive" // plus ".md" or ".json"
 	checkpointPath = outputDir + "/checkpoint.json"
-	workers        = 10
-	userAgent      = "Mozilla/5.0 (compatible; BlogScraper/1.0)"
+	linkReportPath = outputDir + "/link-report.md"
 )
 
-var (
-	httpClient = &http.Client{Timeout: 30 * time.Second}
-	// Pass empty domain — html-to-markdown v1 mangles full URLs with scheme.
-	// Relative links stay relative; boilerplate cleanup handles them.
-	mdConverter = md.NewConverter("", true, nil)
-
-	// Precompiled cleanup regexes
-	reBreadcrumb   = regexp.MustCompile(`(?m)^\[All Articles\]\(/unchained\)\n+`)
-	reDateLine     = regexp.MustCompile(`(?m)^(?:January|February|March|April|May|June|July|August|September|October|November|December) \d{1,2}, \d{4}\n+`)
-	reShareFooter  = regexp.MustCompile(`(?s)\nShare this article.*$`)
-	reRelated      = regexp.MustCompile(`(?s)\nRelated articles\n.*$`)
-	reWantMore     = regexp.MustCompile(`(?s)\n## Want to learn more about Chainguard\?.*$`)
-	reCGCta        = regexp.MustCompile(`(?s)\nChainguard provides a secure foundation.*?\[Get in touch\][^\n]*\n`)
-	reReadyStart   = regexp.MustCompile(`\n_Ready to get started[^\n]*\n`)
-	reNextImage    = regexp.MustCompile(`(?m)^!\[\]\(/_next/image\?url=[^\n]*\)\n`)
-	reExcessBlanks = regexp.MustCompile(`\n{3,}`)
-
-	reDateText = regexp.MustCompile(`^(?:January|February|March|April|May|June|July|August|September|October|November|December) \d{1,2}, \d{4}$`)
-)
-
-// ─── Types ───────────────────────────────────────────────────────────────────
-
-type blogPost struct {
-	Title string
-	URL   string
-	Slug  string
-}
-
-type checkpointEntry struct {
-	Title     string `json:"title"`
-	URL       string `json:"url"`
-	Date      string `json:"date"`
-	ScrapedAt string `json:"scraped_at"`
-}
-
-type checkpoint map[string]checkpointEntry
-
-type scrapeResult struct {
-	slug     string
-	title    string
-	url      string
-	date     string
-	markdown string
-	err      error
-}
-
-// ─── HTTP ────────────────────────────────────────────────────────────────────
-
-func fetchPage(url string) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("User-Agent", userAgent)
-	resp, err := httpClient.Do(req)
+func main() {
+	cfg := scraper.DefaultConfig()
+	force := flag.Bool("force", false, "re-scrape all posts and rebuild the archive from scratch")
+	title := flag.String("title", scraper.DefaultArchiveTitle, "archive H1 title used when the Markdown archive is rebuilt")
+	subtitle := flag.String("subtitle", scraper.DefaultArchiveSubtitle, "Markdown line under the archive title when it is rebuilt; empty to omit")
+	checkLinks := flag.Bool("check-links", false, "after the run, request every link in the archive and write "+linkReportPath+" listing the broken ones by post")
+	refresh := flag.Bool("refresh", false, "also re-check already-archived posts with conditional GETs and replace the ones that changed")
+	inOrder := flag.Bool("insert-in-order", false, "insert new posts at their listing position (newest first) instead of appending them to the end of the archive")
+	flag.StringVar(&cfg.UserAgent, "user-agent", cfg.UserAgent, "User-Agent header for all requests; include contact info so the site operator can reach you")
+	format := flag.String("format", "markdown", `archive format: "markdown" (unchained-archive.md) or "json" (unchained-archive.json, an array of {slug,title,url,date,author,tags,markdown} objects)`)
+	flag.BoolVar(&cfg.UseFeed, "feed", false, "list posts from the blog's RSS/Atom feed instead of the paginated listing when a feed exists (feeds may hold only recent posts)")
+	flag.IntVar(&cfg.MaxPages, "max-pages", cfg.MaxPages, "most listing pages to crawl, a safety cap if next-page detection breaks (0 = no cap)")
+	flag.IntVar(&cfg.MinMarkdownLength, "min-markdown-length", cfg.MinMarkdownLength, "warn when a post's cleaned Markdown is shorter than this many bytes")
+	flag.BoolVar(&cfg.Strict, "strict", false, "treat posts below -min-markdown-length as errors and leave them out of the archive")
+	flag.BoolVar(&cfg.Cleanup.KeepShareFooter, "keep-share-footer", false, `don't cut posts at a "Share this article" line`)
+	flag.BoolVar(&cfg.Cleanup.KeepRelated, "keep-related", false, `don't cut posts at a "Related articles" line`)
+	flag.BoolVar(&cfg.Cleanup.KeepWantMore, "keep-want-more", false, `don't cut posts at a "Want to learn more about Chainguard?" heading`)
+	flag.IntVar(&cfg.MinContentLength, "min-content-length", cfg.MinContentLength, "minimum visible text length (bytes) for an article selector's element to be used as the post body")
+	groupBy := flag.String("group-by", "", `"month" to group the Markdown archive under "## 2025" year and "### January 2025" month headings, newest first, after every write; posts keep their "## Title" headings and undated ones go under "## Undated"`)
+	selectors := flag.String("selectors", "", "comma-separated CSS selectors for the post body, tried before the built-in ones (article, .post-content, main, ...) when the site's markup changes")
+	proxy := flag.String("proxy", "", "proxy URL for all requests (default: HTTP_PROXY/HTTPS_PROXY from the environment)")
+	flag.Parse()
+	cfg.Progress = os.Stdout
+	cfg.Metrics = scraper.NewMetrics()
+	sels, err := scraper.ParseSelectors(*selectors)
 	if err != nil {
-		return "", err
+		log.Fatalf("-selectors: %v", err)
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	return string(body), err
-}
-
-// ─── Listing ─────────────────────────────────────────────────────────────────
-
-func getAllBlogLinks() ([]blogPost, error) {
-	var posts []blogPost
-	seen := make(map[string]bool)
-	fmt.Println("Fetching blog listing pages...")
-
-	for page := 1; ; page++ {
-		url := unchainedURL
-		if page > 1 {
-			url = fmt.Sprintf("%s?page=%d", unchainedURL, page)
-		}
-		fmt.Printf("  Fetching page %d...\n", page)
-
-		html, err := fetchPage(url)
-		if err != nil {
-			return posts, fmt.Errorf("page %d: %w", page, err)
-		}
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	cfg.Selectors = sels
+	if *proxy != "" {
+		transport, err := scraper.NewTransport(*proxy)
 		if err != nil {
-			return posts, err
-		}
-
-		doc.Find(`a[href^="/unchained/"]`).Each(func(_ int, s *goquery.Selection) {
-			href, _ := s.Attr("href")
-			if href == "/unchained" || strings.Contains(href, "/category/") {
-				return
-			}
-			slug := strings.TrimPrefix(href, "/unchained/")
-			if slug == "" || strings.Contains(slug, "?") || seen[slug] {
-				return
-			}
-			seen[slug] = true
-			title := strings.TrimSpace(s.Text())
-			if title == "" {
-				title = slug
-			}
-			posts = append(posts, blogPost{Title: title, URL: baseURL + href, Slug: slug})
-		})
-
-		btn := doc.Find(`button[aria-label="Go to next page"]`)
-		if btn.Length() == 0 {
-			break
-		}
-		if _, disabled := btn.Attr("disabled"); disabled {
-			break
-		}
-	}
-
-	fmt.Printf("Found %d blog posts.\n", len(posts))
-	return posts, nil
-}
-
-// ─── Scraping ────────────────────────────────────────────────────────────────
-
-var articleSelectors = []string{
-	"article", ".post-content", ".blog-content", ".article-content", "main", `[role="main"]`,
-}
-
-func downloadAndConvertPost(post blogPost) scrapeResult {
-	html, err := fetchPage(post.URL)
-	if err != nil {
-		return scrapeResult{slug: post.Slug, err: err}
-	}
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
-	if err != nil {
-		return scrapeResult{slug: post.Slug, err: err}
-	}
-
-	title := post.Title
-	if h1 := strings.TrimSpace(doc.Find("h1").First().Text()); h1 != "" {
-		title = h1
-	}
-
-	var contentHTML string
-	for _, sel := range articleSelectors {
-		el := doc.Find(sel)
-		if el.Length() == 0 {
-			continue
-		}
-		el.Find("nav, header, footer, script, style").Remove()
-		h, _ := el.Html()
-		if len(h) > 100 {
-			contentHTML = h
-			break
+			log.Fatalf("-proxy: %v", err)
 		}
+		cfg.HTTPClient.Transport = transport
 	}
-	if contentHTML == "" {
-		body := doc.Find("body")
-		body.Find("nav, header, footer, script, style").Remove()
-		contentHTML, _ = body.Html()
-	}
-
-	// Extract publish date: prefer <time datetime="..."> in ISO format,
-	// then <time> text, then scan paragraphs for "Month DD, YYYY".
-	date := ""
-	if t := doc.Find("time").First(); t.Length() > 0 {
-		if dt, ok := t.Attr("datetime"); ok && dt != "" {
-			if parsed, parseErr := time.Parse("2006-01-02", dt); parseErr == nil {
-				date = parsed.Format("January 2, 2006")
-			} else {
-				date = dt
-			}
-		} else {
-			date = strings.TrimSpace(t.Text())
-		}
-	}
-	if date == "" {
-		doc.Find("p, div, span").EachWithBreak(func(_ int, s *goquery.Selection) bool {
-			if text := strings.TrimSpace(s.Text()); reDateText.MatchString(text) {
-				date = text
-				return false
-			}
-			return true
-		})
-	}
-
-	rawMD, err := mdConverter.ConvertString(contentHTML)
-	if err != nil {
-		return scrapeResult{slug: post.Slug, err: err}
-	}
-	return scrapeResult{
-		slug:     post.Slug,
-		title:    title,
-		url:      post.URL,
-		date:     date,
-		markdown: cleanMarkdown(rawMD, title),
-	}
-}
-
-func scrapeAll(posts []blogPost) map[string]scrapeResult {
-	out := make(map[string]scrapeResult, len(posts))
-	ch := make(chan scrapeResult, len(posts))
-	sem := make(chan struct{}, workers)
-	var wg sync.WaitGroup
-	var completed atomic.Int32
 
-	for _, post := range posts {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(p blogPost) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			r := downloadAndConvertPost(p)
-			n := int(completed.Add(1))
-			if r.err != nil {
-				fmt.Printf("  [%d/%d] ERROR %s: %v\n", n, len(posts), p.Slug, r.err)
-			} else {
-				fmt.Printf("  [%d/%d] %s\n", n, len(posts), p.Slug)
-			}
-			ch <- r
-		}(post)
+	var archivePath string
+	switch *format {
+	case "markdown", "md":
+		archivePath = archiveBase + ".md"
+	case "json":
+		archivePath = archiveBase + ".json"
+	default:
+		log.Fatalf("unknown -format %q (want markdown or json)", *format)
 	}
-
-	go func() {
-		wg.Wait()
-		close(ch)
-	}()
-
-	for r := range ch {
-		if r.err == nil {
-			out[r.slug] = r
-		}
+	jsonFormat := *format == "json"
+	switch {
+	case *groupBy != "" && *groupBy != "month":
+		log.Fatalf("unknown -group-by %q (want month)", *groupBy)
+	case *groupBy != "" && jsonFormat:
+		log.Fatal("-group-by applies only to the markdown format")
 	}
-	return out
-}
-
-// ─── Cleanup ─────────────────────────────────────────────────────────────────
-
-func cleanMarkdown(raw, title string) string {
-	s := raw
-	s = reBreadcrumb.ReplaceAllString(s, "")
-	s = reDateLine.ReplaceAllString(s, "")
-
-	// Remove duplicate H1 (title already appears as H2 in the combined file)
-	reH1 := regexp.MustCompile(`(?m)^# ` + regexp.QuoteMeta(title) + `\s*\n+`)
-	s = reH1.ReplaceAllString(s, "")
-
-	s = reShareFooter.ReplaceAllString(s, "")
-	s = reRelated.ReplaceAllString(s, "")
-	s = reWantMore.ReplaceAllString(s, "")
-	s = reCGCta.ReplaceAllString(s, "")
-	s = reReadyStart.ReplaceAllString(s, "")
-	s = reNextImage.ReplaceAllString(s, "")
-	s = reExcessBlanks.ReplaceAllString(s, "\n\n")
-	return strings.TrimSpace(s)
-}
-
-// ─── Output ──────────────────────────────────────────────────────────────────
-
-func formatPost(r scrapeResult) string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("## %s\n\n", r.title))
-	if r.date != "" {
-		sb.WriteString(fmt.Sprintf("*Source: %s | %s*\n\n", r.url, r.date))
-	} else {
-		sb.WriteString(fmt.Sprintf("*Source: %s*\n\n", r.url))
-	}
-	sb.WriteString(r.markdown)
-	sb.WriteString("\n\n---\n\n")
-	return sb.String()
-}
-
-// ─── Checkpoint ──────────────────────────────────────────────────────────────
-
-func loadCheckpoint() checkpoint {
-	cp := make(checkpoint)
-	data, err := os.ReadFile(checkpointPath)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Warning: could not read checkpoint: %v", err)
-		}
-		return cp
-	}
-	if err := json.Unmarshal(data, &cp); err != nil {
-		log.Printf("Warning: could not parse checkpoint: %v", err)
-	}
-	return cp
-}
-
-func saveCheckpoint(cp checkpoint) {
-	data, _ := json.MarshalIndent(cp, "", "  ")
-	if err := os.WriteFile(checkpointPath, data, 0644); err != nil {
-		log.Printf("Warning: could not save checkpoint: %v", err)
-	}
-}
-
-// ─── Main ────────────────────────────────────────────────────────────────────
-
-func main() {
-	force := flag.Bool("force", false, "re-scrape all posts and rebuild the archive from scratch")
-	flag.Parse()
 
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
 		log.Fatalf("mkdir: %v", err)
 	}
 
-	cp := loadCheckpoint()
+	cp, err := scraper.LoadCheckpoint(checkpointPath)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
 
-	allPosts, err := getAllBlogLinks()
+	allPosts, err := scraper.ListPosts(&cfg)
 	if err != nil {
 		log.Fatalf("listing: %v", err)
 	}
@@ -338,81 +93,258 @@ func main() {
 		log.Fatal("no posts found")
 	}
 
-	// On -force, ignore the checkpoint and scrape everything.
-	// Otherwise, only scrape slugs not yet in the checkpoint.
-	var toScrape []blogPost
-	if *force {
+	// The checkpoint only says what is in the archive, so a missing archive
+	// (first run, or first run with a new -format) means a full rebuild.
+	_, archiveErr := os.Stat(archivePath)
+	rebuild := *force || os.IsNotExist(archiveErr)
+
+	// On rebuild, ignore the checkpoint and scrape everything.
+	// Otherwise, only scrape slugs not yet in the checkpoint, plus on
+	// -refresh the archived ones, sending their stored validators so
+	// unchanged posts cost a 304 and are skipped.
+	var toScrape []scraper.Post
+	if rebuild {
 		toScrape = allPosts
-		cp = make(checkpoint)
-		fmt.Printf("\nForce mode: re-scraping all %d posts.\n", len(toScrape))
+		cp = make(scraper.Checkpoint)
+		if *force {
+			fmt.Printf("\nForce mode: re-scraping all %d posts.\n", len(toScrape))
+		} else {
+			fmt.Printf("\nNo archive at %s: scraping all %d posts.\n", archivePath, len(toScrape))
+		}
 	} else {
 		for _, p := range allPosts {
-			if _, ok := cp[p.Slug]; !ok {
+			if key, ok := cp.Resolve(p.Slug); !ok {
+				toScrape = append(toScrape, p)
+			} else if *refresh {
+				e := cp[key]
+				p.Since = scraper.Validators{ETag: e.ETag, LastModified: e.LastModified}
 				toScrape = append(toScrape, p)
 			}
 		}
 	}
 
+	cfg.Metrics.AddCached(len(allPosts) - len(toScrape))
+
 	if len(toScrape) == 0 {
 		fmt.Println("All posts up to date.")
+		if *groupBy == "month" {
+			if err := scraper.GroupArchiveByMonth(archivePath); err != nil {
+				log.Fatalf("group archive: %v", err)
+			}
+		}
+		if *checkLinks {
+			runLinkCheck(&cfg, archivePath, jsonFormat)
+		}
+		fmt.Print("\n", cfg.Metrics.Snapshot())
 		return
 	}
 
-	if !*force {
+	if *refresh && !rebuild {
+		fmt.Printf("\nChecking %d posts for changes...\n", len(toScrape))
+	} else if !rebuild {
 		fmt.Printf("\nScraping %d new posts (%d already cached)...\n",
 			len(toScrape), len(allPosts)-len(toScrape))
 	}
 
-	scraped := scrapeAll(toScrape)
+	// Section headings must be unique: reused series names would otherwise
+	// produce duplicate "## Title" sections and ambiguous anchors. The
+	// checkpoint holds the headings of posts already in the archive.
+	titles := scraper.NewTitleSet()
+	for slug, e := range cp {
+		titles.Add(e.Title, slug)
+	}
 
-	// Update checkpoint with newly scraped posts.
+	// accept disambiguates each post's title and records it in the
+	// checkpoint just before the post is written out. Posts are keyed by
+	// their final slug, so one listed under an old slug that redirects to a
+	// post already written (this run or before) is dropped, and the old slug
+	// recorded as an alias so later runs don't fetch it again.
 	now := time.Now().UTC().Format(time.RFC3339)
-	for slug, r := range scraped {
-		cp[slug] = checkpointEntry{
-			Title:     r.title,
-			URL:       r.url,
-			Date:      r.date,
-			ScrapedAt: now,
+	accepted := make(map[string]bool)
+	accept := func(r scraper.Result) (scraper.Result, bool) {
+		e, archived := cp[r.Slug]
+		aliased := r.ListedSlug != "" && slices.Contains(e.Aliases, r.ListedSlug)
+		if r.ListedSlug != "" && !aliased {
+			e.Aliases = append(e.Aliases, r.ListedSlug)
+		}
+		if accepted[r.Slug] || (r.ListedSlug != "" && archived && !aliased) {
+			fmt.Printf("  skipped %s: same post as %s, already archived\n", r.URL, r.Slug)
+			if archived {
+				cp[r.Slug] = e
+			}
+			return r, false
+		}
+		accepted[r.Slug] = true
+		if r.ListedSlug != "" {
+			delete(cp, r.ListedSlug)
+		}
+
+		title, clash := titles.Unique(r.Title, r.Slug)
+		if clash != "" {
+			log.Printf("Warning: posts %s and %s share the title %q; using %q for %s",
+				clash, r.Slug, r.Title, title, r.Slug)
+			r.Title = title
 		}
+		cp[r.Slug] = scraper.CheckpointEntry{
+			Title:        r.Title,
+			URL:          r.URL,
+			Date:         r.Date,
+			Author:       r.Author,
+			Tags:         r.Tags,
+			Excerpt:      r.Excerpt,
+			ScrapedAt:    now,
+			ETag:         r.ETag,
+			LastModified: r.LastModified,
+			Aliases:      e.Aliases,
+		}
+		return r, true
 	}
-	saveCheckpoint(cp)
 
 	// Write output.
 	// -force or no existing archive: rebuild the full file in listing order.
-	// Incremental: append new posts in listing order.
-	_, archiveErr := os.Stat(archivePath)
-	rebuild := *force || os.IsNotExist(archiveErr)
-
-	if rebuild {
-		f, err := os.Create(archivePath)
-		if err != nil {
-			log.Fatalf("create archive: %v", err)
-		}
-		defer f.Close()
-		f.WriteString("# Unchained Blog Archive\n\n")
-		f.WriteString("*Articles from [chainguard.dev/unchained](https://chainguard.dev/unchained)*\n\n")
-		f.WriteString("---\n\n")
-		n := 0
-		for _, p := range allPosts {
-			if r, ok := scraped[p.Slug]; ok {
-				f.WriteString(formatPost(r))
-				n++
+	// Incremental: append new posts in listing order, or with
+	// -insert-in-order rewrite the file with them in listing position.
+	// Except for -insert-in-order and JSON appends, which rewrite the file
+	// and so need every new post first, each post is written as soon as it
+	// is scraped. -refresh always rewrites in order, since changed posts
+	// replace their existing sections.
+	//
+	// The checkpoint must never list a post the archive lacks, or later
+	// runs would skip it for good: a rebuild goes to a temporary file that
+	// replaces the archive only once complete, with the checkpoint saved
+	// after it, and an append saves the checkpoint after each post.
+	switch {
+	case rebuild:
+		var n int
+		err := scraper.RebuildFile(archivePath, func(w io.Writer) error {
+			if jsonFormat {
+				n = writeEachJSON(&cfg, w, toScrape, accept)
+				return nil
+			}
+			if _, err := io.WriteString(w, scraper.ArchiveHeader(*title, *subtitle)); err != nil {
+				return err
 			}
+			n = scraper.WriteEach(&cfg, w, toScrape, accept, nil)
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("write archive: %v", err)
 		}
 		fmt.Printf("\nDone! Archive rebuilt with %d posts: %s\n", n, archivePath)
-	} else {
+	case jsonFormat:
+		scraped := acceptAll(&cfg, toScrape, accept)
+		n, err := scraper.AppendJSON(archivePath, allPosts, scraped, *inOrder || *refresh)
+		if err != nil {
+			log.Fatalf("rewrite archive: %v", err)
+		}
+		fmt.Printf("\nDone! %d new posts added to %s\n", n, archivePath)
+	case *inOrder || *refresh:
+		scraped := acceptAll(&cfg, toScrape, accept)
+		n, err := scraper.InsertInOrder(archivePath, allPosts, scraped)
+		if err != nil {
+			log.Fatalf("rewrite archive: %v", err)
+		}
+		fmt.Printf("\nDone! %d new or changed posts written to %s\n", n, archivePath)
+	default:
 		f, err := os.OpenFile(archivePath, os.O_APPEND|os.O_WRONLY, 0644)
 		if err != nil {
 			log.Fatalf("open archive: %v", err)
 		}
 		defer f.Close()
-		n := 0
-		for _, p := range allPosts {
-			if r, ok := scraped[p.Slug]; ok {
-				f.WriteString(formatPost(r))
-				n++
+		n := scraper.WriteEach(&cfg, f, toScrape, accept, func() {
+			if err := scraper.SaveCheckpoint(checkpointPath, cp); err != nil {
+				log.Printf("Warning: %v", err)
 			}
-		}
+		})
 		fmt.Printf("\nDone! %d new posts appended to %s\n", n, archivePath)
 	}
+
+	if *groupBy == "month" {
+		if err := scraper.GroupArchiveByMonth(archivePath); err != nil {
+			log.Fatalf("group archive: %v", err)
+		}
+	}
+
+	if err := scraper.SaveCheckpoint(checkpointPath, cp); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if *checkLinks {
+		runLinkCheck(&cfg, archivePath, jsonFormat)
+	}
+	fmt.Print("\n", cfg.Metrics.Snapshot())
+}
+
+// runLinkCheck checks every link in the archive and writes the broken ones
+// to linkReportPath. Failures are logged, not fatal: the archive is already
+// written.
+func runLinkCheck(cfg *scraper.Config, archivePath string, jsonFormat bool) {
+	sources, err := scraper.ArchiveLinkSources(archivePath, jsonFormat)
+	if err != nil {
+		log.Printf("Warning: link check: %v", err)
+		return
+	}
+	links := make(map[string][]string, len(sources))
+	var urls []string
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		links[src.Slug] = scraper.ExtractLinks(cfg, src.Markdown)
+		for _, u := range links[src.Slug] {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+
+	fmt.Printf("\nChecking %d links in %d posts...\n", len(urls), len(sources))
+	report, n := scraper.LinkReport(sources, links, scraper.CheckLinks(cfg, urls))
+	if err := os.WriteFile(linkReportPath, []byte(report), 0o644); err != nil {
+		log.Printf("Warning: link check: %v", err)
+		return
+	}
+	fmt.Printf("%d broken links: %s\n", n, linkReportPath)
+}
+
+// writeEachJSON is scraper.WriteEach for the JSON format, wrapping the
+// posts in an array.
+func writeEachJSON(cfg *scraper.Config, w io.Writer, posts []scraper.Post, accept func(scraper.Result) (scraper.Result, bool)) int {
+	out, err := scraper.NewJSONArchiveWriter(w)
+	if err != nil {
+		log.Fatalf("write archive: %v", err)
+	}
+	n := 0
+	scraper.ScrapeEach(cfg, posts, func(r scraper.Result) {
+		r, ok := accept(r)
+		if !ok {
+			return
+		}
+		if err := out.Write(r); err != nil {
+			log.Fatalf("write archive: %v", err)
+		}
+		n++
+	})
+	if err := out.Close(); err != nil {
+		log.Fatalf("write archive: %v", err)
+	}
+	return n
+}
+
+// acceptAll scrapes posts and passes each result through accept in listing
+// order, for the write paths that need every new post before writing. The
+// results are keyed by listing slug, which the in-order writers place by.
+func acceptAll(cfg *scraper.Config, posts []scraper.Post, accept func(scraper.Result) (scraper.Result, bool)) map[string]scraper.Result {
+	out := make(map[string]scraper.Result, len(posts))
+	scraper.ScrapeEach(cfg, posts, func(r scraper.Result) {
+		r, ok := accept(r)
+		if !ok {
+			return
+		}
+		key := r.Slug
+		if r.ListedSlug != "" {
+			key = r.ListedSlug
+		}
+		out[key] = r
+	})
+	return out
 }
@@ -0,0 +1,198 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Default archive header text, as used by the CLI unless -title or
+// -subtitle override it.
+const (
+	DefaultArchiveTitle    = "Unchained Blog Archive"
+	DefaultArchiveSubtitle = "*Articles from [chainguard.dev/unchained](https://chainguard.dev/unchained)*"
+)
+
+// ArchiveHeader returns the opening of a freshly built archive: an H1 title,
+// the subtitle line (Markdown, omitted when empty), and a separator.
+func ArchiveHeader(title, subtitle string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n\n", title))
+	if subtitle != "" {
+		sb.WriteString(subtitle + "\n\n")
+	}
+	sb.WriteString("---\n\n")
+	return sb.String()
+}
+
+// Start of an archive section as written by FormatPost. The Source line
+// distinguishes a post's H2 title from H2s inside its body.
+var reSectionStart = regexp.MustCompile(`(?m)^## [^\n]*\n\n\*Source: (\S+?)(?: \||\*)`)
+
+// FormatPost renders a result as an archive section: an H2 title, an italic
+// Source line with the date when known, italic Author, Tags and Excerpt lines
+// when the post has them, the body, and a trailing separator. Downstream
+// parsers depend on the exact shape of the title and Source lines, so these
+// get lines of their own.
+func FormatPost(r Result) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## %s\n\n", r.Title))
+	if r.Date != "" {
+		sb.WriteString(fmt.Sprintf("*Source: %s | %s*\n\n", r.URL, r.Date))
+	} else {
+		sb.WriteString(fmt.Sprintf("*Source: %s*\n\n", r.URL))
+	}
+	if r.Author != "" {
+		sb.WriteString(fmt.Sprintf("*Author: %s*\n\n", r.Author))
+	}
+	if len(r.Tags) > 0 {
+		sb.WriteString(fmt.Sprintf("*Tags: %s*\n\n", strings.Join(r.Tags, ", ")))
+	}
+	if r.Excerpt != "" {
+		sb.WriteString(fmt.Sprintf("*Excerpt: %s*\n\n", r.Excerpt))
+	}
+	sb.WriteString(r.Markdown)
+	sb.WriteString("\n\n---\n\n")
+	return sb.String()
+}
+
+// ArchiveSection is one post's block in an existing archive, from its
+// "## Title" line through the trailing "---" separator.
+type ArchiveSection struct {
+	URL  string
+	Text string
+}
+
+// ParseArchive splits an archive into its header (title, intro and first
+// separator) and its post sections, in file order.
+func ParseArchive(s string) (header string, sections []ArchiveSection) {
+	locs := reSectionStart.FindAllStringSubmatchIndex(s, -1)
+	if len(locs) == 0 {
+		return s, nil
+	}
+	header = s[:locs[0][0]]
+	for i, loc := range locs {
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		sections = append(sections, ArchiveSection{URL: s[loc[2]:loc[3]], Text: s[loc[0]:end]})
+	}
+	return header, sections
+}
+
+// WriteEach scrapes posts and writes each result to w as it completes, in
+// listing order, passing it through accept first and dropping the ones it
+// rejects. written, if non-nil, is called after each post is written, so a
+// caller appending to a live archive can save its checkpoint there: the
+// checkpoint then never lists a post the archive lacks, however the run is
+// interrupted. Returns the number of posts written.
+func WriteEach(cfg *Config, w io.Writer, posts []Post, accept func(Result) (Result, bool), written func()) int {
+	n := 0
+	ScrapeEach(cfg, posts, func(r Result) {
+		if r, ok := accept(r); ok {
+			io.WriteString(w, FormatPost(r))
+			n++
+			if written != nil {
+				written()
+			}
+		}
+	})
+	return n
+}
+
+// RebuildFile writes a new archive with write to a temporary file beside
+// path and renames it over path only once write succeeds, so an interrupted
+// or failed rebuild leaves the previous archive intact.
+func RebuildFile(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// InsertInOrder rewrites the archive at path with newly scraped posts placed
+// at their listing position instead of appended at the end. Existing sections
+// are kept verbatim; any whose post is no longer listed stay at the end in
+// their original order. Returns the number of posts inserted.
+func InsertInOrder(path string, allPosts []Post, scraped map[string]Result) (int, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	header, sections := ParseArchive(string(existing))
+	byURL := make(map[string]ArchiveSection, len(sections))
+	for _, sec := range sections {
+		byURL[sec.URL] = sec
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header)
+	written := make(map[string]bool, len(sections))
+	n := 0
+	for _, p := range allPosts {
+		if r, ok := scraped[p.Slug]; ok {
+			sb.WriteString(FormatPost(r))
+			written[r.URL] = true
+			written[p.URL] = true // a redirected post's old section
+			n++
+		} else if sec, ok := byURL[p.URL]; ok && !written[p.URL] {
+			sb.WriteString(sec.Text)
+			written[p.URL] = true
+		}
+	}
+	for _, sec := range sections {
+		if !written[sec.URL] {
+			sb.WriteString(sec.Text)
+			written[sec.URL] = true
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp, path)
+}
+
+// TitleSet keeps archive section headings unique. Titles are compared
+// case-insensitively, as heading anchors are.
+type TitleSet struct {
+	slugs map[string]string // lowercased title -> slug whose heading it is
+}
+
+// NewTitleSet returns an empty TitleSet.
+func NewTitleSet() *TitleSet {
+	return &TitleSet{slugs: make(map[string]string)}
+}
+
+// Add records a heading already in the archive.
+func (s *TitleSet) Add(title, slug string) {
+	s.slugs[strings.ToLower(title)] = slug
+}
+
+// Unique returns title if no other post's heading uses it, or the title with
+// " (slug)" appended if one does, and records the result. clash is the slug
+// already holding title, or "" when there was no collision.
+func (s *TitleSet) Unique(title, slug string) (unique, clash string) {
+	unique = title
+	if other, ok := s.slugs[strings.ToLower(title)]; ok && other != slug {
+		clash = other
+		unique = fmt.Sprintf("%s (%s)", title, slug)
+	}
+	s.Add(unique, slug)
+	return unique, clash
+}
@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ArchivePost is one post in a JSON archive.
+type ArchivePost struct {
+	Slug     string   `json:"slug"`
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Date     string   `json:"date"`
+	Author   string   `json:"author,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Excerpt  string   `json:"excerpt,omitempty"`
+	Markdown string   `json:"markdown"`
+}
+
+func archivePost(r Result) ArchivePost {
+	return ArchivePost{Slug: r.Slug, Title: r.Title, URL: r.URL, Date: r.Date, Author: r.Author, Tags: r.Tags, Excerpt: r.Excerpt, Markdown: r.Markdown}
+}
+
+// FormatPostJSON renders a result as one element of a JSON archive array:
+// an indented object without a trailing comma or newline. It is the JSON
+// counterpart of FormatPost.
+func FormatPostJSON(r Result) (string, error) {
+	return formatArchivePost(archivePost(r))
+}
+
+func formatArchivePost(p ArchivePost) (string, error) {
+	data, err := json.MarshalIndent(p, "  ", "  ")
+	if err != nil {
+		return "", err
+	}
+	return "  " + string(data), nil
+}
+
+// JSONArchiveWriter streams posts to w as a JSON array. Close writes the
+// closing bracket and must be called even when no posts were written.
+type JSONArchiveWriter struct {
+	w io.Writer
+	n int
+}
+
+// NewJSONArchiveWriter starts a JSON array on w.
+func NewJSONArchiveWriter(w io.Writer) (*JSONArchiveWriter, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	return &JSONArchiveWriter{w: w}, nil
+}
+
+// Write appends r to the array.
+func (a *JSONArchiveWriter) Write(r Result) error {
+	return a.write(archivePost(r))
+}
+
+func (a *JSONArchiveWriter) write(p ArchivePost) error {
+	s, err := formatArchivePost(p)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if a.n == 0 {
+		sep = "\n"
+	}
+	a.n++
+	_, err = io.WriteString(a.w, sep+s)
+	return err
+}
+
+// Close ends the array.
+func (a *JSONArchiveWriter) Close() error {
+	end := "\n]\n"
+	if a.n == 0 {
+		end = "]\n"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// AppendJSON rewrites the JSON archive at path with newly scraped posts added:
+// at the end in listing order, or with inOrder at their listing position the
+// way InsertInOrder does for Markdown. Returns the number of posts added.
+func AppendJSON(path string, allPosts []Post, scraped map[string]Result, inOrder bool) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var existing []ArchivePost
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	var sb strings.Builder
+	out, err := NewJSONArchiveWriter(&sb)
+	if err != nil {
+		return 0, err
+	}
+	written := make(map[string]bool, len(existing))
+	n := 0
+	if inOrder {
+		byURL := make(map[string]ArchivePost, len(existing))
+		for _, p := range existing {
+			byURL[p.URL] = p
+		}
+		for _, post := range allPosts {
+			if r, ok := scraped[post.Slug]; ok {
+				if err := out.Write(r); err != nil {
+					return 0, err
+				}
+				written[r.URL] = true
+				written[post.URL] = true // a redirected post's old section
+				n++
+			} else if p, ok := byURL[post.URL]; ok && !written[post.URL] {
+				if err := out.write(p); err != nil {
+					return 0, err
+				}
+				written[post.URL] = true
+			}
+		}
+	}
+	for _, p := range existing {
+		if !written[p.URL] {
+			if err := out.write(p); err != nil {
+				return 0, err
+			}
+			written[p.URL] = true
+		}
+	}
+	if !inOrder {
+		for _, post := range allPosts {
+			if r, ok := scraped[post.Slug]; ok {
+				if err := out.Write(r); err != nil {
+					return 0, err
+				}
+				n++
+			}
+		}
+	}
+	if err := out.Close(); err != nil {
+		return 0, err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp, path)
+}
@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// CheckpointEntry records a post that has already been scraped.
+type CheckpointEntry struct {
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Date      string   `json:"date"`
+	Author    string   `json:"author,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Excerpt   string   `json:"excerpt,omitempty"`
+	ScrapedAt string   `json:"scraped_at"`
+	// Aliases are slugs the post was listed under that redirect to it.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Cache validators from the last fetch, for -refresh's conditional GETs.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Checkpoint maps post slugs to their scrape records.
+type Checkpoint map[string]CheckpointEntry
+
+// Resolve returns the key of the entry for slug, which is slug itself or the
+// post slug redirects to.
+func (cp Checkpoint) Resolve(slug string) (string, bool) {
+	if _, ok := cp[slug]; ok {
+		return slug, true
+	}
+	for key, e := range cp {
+		for _, alias := range e.Aliases {
+			if alias == slug {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// LoadCheckpoint reads the checkpoint at path. A missing file yields an empty
+// checkpoint; an unreadable or corrupt one yields an empty checkpoint and an
+// error the caller may treat as a warning.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	cp := make(Checkpoint)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		return cp, fmt.Errorf("could not read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return make(Checkpoint), fmt.Errorf("could not parse checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// SaveCheckpoint writes cp to path as indented JSON. It writes a temporary
+// file and renames it into place, so a run interrupted mid-save keeps the
+// previous checkpoint rather than a truncated one.
+func SaveCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("could not save checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not save checkpoint: %w", err)
+	}
+	return nil
+}
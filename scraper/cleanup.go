@@ -0,0 +1,58 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Precompiled cleanup regexes
+var (
+	reBreadcrumb   = regexp.MustCompile(`(?m)^\[All Articles\]\(/unchained\)\n+`)
+	reDateLine     = regexp.MustCompile(`(?m)^(?:January|February|March|April|May|June|July|August|September|October|November|December) \d{1,2}, \d{4}\n+`)
+	reShareFooter  = regexp.MustCompile(`(?s)\nShare this article.*$`)
+	reRelated      = regexp.MustCompile(`(?s)\nRelated articles\n.*$`)
+	reWantMore     = regexp.MustCompile(`(?s)\n## Want to learn more about Chainguard\?.*$`)
+	reCGCta        = regexp.MustCompile(`(?s)\nChainguard provides a secure foundation.*?\[Get in touch\][^\n]*\n`)
+	reReadyStart   = regexp.MustCompile(`\n_Ready to get started[^\n]*\n`)
+	reNextImage    = regexp.MustCompile(`(?m)^!\[\]\(/_next/image\?url=[^\n]*\)\n`)
+	reExcessBlanks = regexp.MustCompile(`\n{3,}`)
+)
+
+// CleanOptions turns off the cleanup rules that cut everything from a marker
+// line to the end of the post, for posts where that would lose real content.
+// The zero value applies every rule.
+type CleanOptions struct {
+	KeepShareFooter bool // keep from a "Share this article" line on
+	KeepRelated     bool // keep from a "Related articles" line on
+	KeepWantMore    bool // keep from a "## Want to learn more about Chainguard?" heading on
+}
+
+// CleanMarkdown strips site boilerplate (breadcrumb, date line, share and
+// related-articles footers, calls to action, Next.js image stubs) and the
+// duplicate title H1 from a converted post. The trailing-cut rules match
+// only whole marker lines, so a paragraph that merely mentions "related
+// articles" is kept; opts can disable them entirely.
+func CleanMarkdown(raw, title string, opts CleanOptions) string {
+	s := raw
+	s = reBreadcrumb.ReplaceAllString(s, "")
+	s = reDateLine.ReplaceAllString(s, "")
+
+	// Remove duplicate H1 (title already appears as H2 in the combined file)
+	reH1 := regexp.MustCompile(`(?m)^# ` + regexp.QuoteMeta(title) + `\s*\n+`)
+	s = reH1.ReplaceAllString(s, "")
+
+	if !opts.KeepShareFooter {
+		s = reShareFooter.ReplaceAllString(s, "")
+	}
+	if !opts.KeepRelated {
+		s = reRelated.ReplaceAllString(s, "")
+	}
+	if !opts.KeepWantMore {
+		s = reWantMore.ReplaceAllString(s, "")
+	}
+	s = reCGCta.ReplaceAllString(s, "")
+	s = reReadyStart.ReplaceAllString(s, "")
+	s = reNextImage.ReplaceAllString(s, "")
+	s = reExcessBlanks.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
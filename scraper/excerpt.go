@@ -0,0 +1,82 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// excerptLength is the longest excerpt, in bytes, before the ellipsis.
+	excerptLength = 200
+	// minExcerptWords is the fewest words a paragraph needs to be chosen as
+	// the excerpt; shorter ones are usually captions or lead-ins.
+	minExcerptWords = 12
+)
+
+var (
+	reCodeFence  = regexp.MustCompile("(?ms)^```.*?^```[^\\n]*$")
+	reMDImage    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	reMDLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	reMDEmphasis = regexp.MustCompile("[*_`]+")
+	reWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// Excerpt returns a plain-text description of a cleaned post: its first
+// paragraph of at least minExcerptWords words, skipping headings, images,
+// lists, quotes, tables and code, cut on a word boundary to about
+// excerptLength bytes. When no paragraph is long enough it uses the first
+// prose paragraph of any length, and "" when there is none.
+func Excerpt(markdown string) string {
+	markdown = reCodeFence.ReplaceAllString(markdown, "")
+	var fallback string
+	for _, block := range strings.Split(markdown, "\n\n") {
+		block = strings.TrimSpace(block)
+		if block == "" || block == "---" || strings.ContainsAny(block[:1], "#>|") || isListItem(block) {
+			continue
+		}
+		text := reMDImage.ReplaceAllString(block, "")
+		text = reMDLink.ReplaceAllString(text, "$1")
+		text = reMDEmphasis.ReplaceAllString(text, "")
+		text = strings.TrimSpace(reWhitespace.ReplaceAllString(text, " "))
+		if text == "" {
+			continue
+		}
+		if len(strings.Fields(text)) >= minExcerptWords {
+			return truncateWords(text, excerptLength)
+		}
+		if fallback == "" {
+			fallback = text
+		}
+	}
+	return truncateWords(fallback, excerptLength)
+}
+
+// isListItem reports whether s opens with a list marker: "- ", "* ", "+ ",
+// or an ordered one such as "1. " or "12) ".
+func isListItem(s string) bool {
+	if strings.HasPrefix(s, "- ") || strings.HasPrefix(s, "* ") || strings.HasPrefix(s, "+ ") {
+		return true
+	}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i > 0 && i+1 < len(s) && (s[i] == '.' || s[i] == ')') && s[i+1] == ' '
+}
+
+// truncateWords cuts s to at most n bytes at the last space and appends an
+// ellipsis, or returns s unchanged when it fits.
+func truncateWords(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := strings.LastIndex(s[:n+1], " ")
+	if cut <= 0 {
+		cut = n
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+	}
+	return strings.TrimRight(s[:cut], " ,;:.-") + "…"
+}
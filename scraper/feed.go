@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// errNoFeed means neither the conventional feed URL nor the listing page's
+// <link rel="alternate"> led to a parseable feed.
+var errNoFeed = errors.New("no RSS or Atom feed found")
+
+// FeedURL returns the conventional location of the blog's feed.
+func (c *Config) FeedURL() string {
+	return c.ListingURL() + "/feed.xml"
+}
+
+// feedDoc decodes both RSS 2.0 (<rss><channel><item>) and Atom
+// (<feed><entry>); only the fields matching the root element are filled.
+type feedDoc struct {
+	XMLName xml.Name
+	Items   []struct {
+		Title   string `xml:"title"`
+		Link    string `xml:"link"`
+		PubDate string `xml:"pubDate"`
+	} `xml:"channel>item"`
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// FeedPosts lists posts from the blog's RSS or Atom feed, trying FeedURL and
+// then any feed the listing page advertises with <link rel="alternate">.
+// Posts come back in feed order with Date set when the feed has one. Entries
+// that are not blog posts on cfg.BaseURL are skipped.
+func FeedPosts(cfg *Config) ([]Post, error) {
+	candidates := []string{cfg.FeedURL()}
+	if html, err := FetchPage(cfg, cfg.ListingURL()); err == nil {
+		if doc, err := goquery.NewDocumentFromReader(strings.NewReader(html)); err == nil {
+			doc.Find(`link[rel="alternate"]`).Each(func(_ int, s *goquery.Selection) {
+				typ := s.AttrOr("type", "")
+				href := s.AttrOr("href", "")
+				if href == "" || (typ != "application/rss+xml" && typ != "application/atom+xml") {
+					return
+				}
+				if u, err := url.Parse(cfg.ListingURL()); err == nil {
+					if ref, err := u.Parse(href); err == nil {
+						href = ref.String()
+					}
+				}
+				candidates = append(candidates, href)
+			})
+		}
+	}
+
+	for _, feedURL := range candidates {
+		page, _, err := fetch(cfg, feedURL, Validators{})
+		if err != nil {
+			continue
+		}
+		if posts, err := parseFeed(cfg, page.Body); err == nil && len(posts) > 0 {
+			cfg.printf("  Using feed %s\n", feedURL)
+			return posts, nil
+		}
+	}
+	return nil, errNoFeed
+}
+
+func parseFeed(cfg *Config, body string) ([]Post, error) {
+	var feed feedDoc
+	if err := xml.Unmarshal([]byte(body), &feed); err != nil {
+		return nil, err
+	}
+	var posts []Post
+	seen := make(map[string]bool)
+	add := func(title, link, date string) {
+		if !strings.HasPrefix(link, cfg.BaseURL+"/unchained/") {
+			return
+		}
+		href := canonicalPath(strings.TrimPrefix(link, cfg.BaseURL))
+		slug := strings.TrimPrefix(href, "/unchained/")
+		if slug == "" || strings.HasPrefix(href, "/unchained/category/") || seen[slug] {
+			return
+		}
+		seen[slug] = true
+		title = strings.TrimSpace(title)
+		if title == "" {
+			title = slug
+		}
+		posts = append(posts, Post{Title: title, URL: cfg.BaseURL + href, Slug: slug, Date: feedDate(date)})
+	}
+
+	switch feed.XMLName.Local {
+	case "rss":
+		for _, it := range feed.Items {
+			add(it.Title, strings.TrimSpace(it.Link), it.PubDate)
+		}
+	case "feed":
+		for _, e := range feed.Entries {
+			link := ""
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			date := e.Published
+			if date == "" {
+				date = e.Updated
+			}
+			add(e.Title, link, date)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected feed root <%s>", feed.XMLName.Local)
+	}
+	return posts, nil
+}
+
+// feedDate converts an RSS (RFC 1123) or Atom (RFC 3339) timestamp to the
+// archive's "January 2, 2006" form, or "" if it doesn't parse.
+func feedDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("January 2, 2006")
+		}
+	}
+	return ""
+}
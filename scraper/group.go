@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UndatedGroup is the heading for posts whose date is missing or unparsable
+// in a grouped archive.
+const UndatedGroup = "Undated"
+
+var (
+	// Group headings as written by GroupArchiveByMonth, which always sit
+	// directly before a post section, so at the end of the text before it.
+	reGroupHeadings = regexp.MustCompile(`(?m)(?:^(?:## (?:\d{4}|` + UndatedGroup + `)|### (?:January|February|March|April|May|June|July|August|September|October|November|December) \d{4})\n\n)+\z`)
+	reSourceDate    = regexp.MustCompile(`(?m)^\*Source: \S+ \| ([^*\n]+)\*$`)
+)
+
+// dateLayouts are the date forms a Source line may carry: ParsePost's own
+// format first, then raw <time> and feed values it passes through.
+var dateLayouts = []string{"January 2, 2006", "Jan 2, 2006", "2006-01-02", time.RFC3339}
+
+// GroupArchiveByMonth rewrites the Markdown archive at path with its posts
+// grouped by publish month, newest first: a "## 2025" heading for each year
+// and a "### January 2025" heading for each month, with posts keeping their
+// "## Title" headings beneath them and their archive order within a month.
+// Posts without a usable date go last under "## Undated". Headings from an
+// earlier grouping are dropped first, so it can run after every write.
+func GroupArchiveByMonth(path string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	header, sections := ParseArchive(string(existing))
+
+	type dated struct {
+		ArchiveSection
+		date time.Time // zero when undated
+	}
+	posts := make([]dated, len(sections))
+	for i, sec := range sections {
+		posts[i] = dated{ArchiveSection{URL: sec.URL, Text: reGroupHeadings.ReplaceAllString(sec.Text, "")}, sectionDate(sec.Text)}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		a, b := posts[i].date, posts[j].date
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Year() > b.Year() || (a.Year() == b.Year() && a.Month() > b.Month())
+	})
+
+	var sb strings.Builder
+	sb.WriteString(reGroupHeadings.ReplaceAllString(header, ""))
+	var year, month string
+	for _, p := range posts {
+		switch {
+		case p.date.IsZero() && year != UndatedGroup:
+			year = UndatedGroup
+			sb.WriteString("## " + UndatedGroup + "\n\n")
+		case !p.date.IsZero():
+			if y := p.date.Format("2006"); y != year {
+				year, month = y, ""
+				sb.WriteString("## " + y + "\n\n")
+			}
+			if m := p.date.Format("January 2006"); m != month {
+				month = m
+				sb.WriteString("### " + m + "\n\n")
+			}
+		}
+		sb.WriteString(p.Text)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sectionDate parses the date from a section's Source line, or returns the
+// zero time.
+func sectionDate(text string) time.Time {
+	m := reSourceDate.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(m[1])); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
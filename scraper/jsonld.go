@@ -0,0 +1,123 @@
+package scraper
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// postingTypes are the schema.org types whose JSON-LD describes the post
+// itself rather than the site, breadcrumb or organization.
+var postingTypes = map[string]bool{"BlogPosting": true, "Article": true, "NewsArticle": true, "TechArticle": true}
+
+// linkedData is the subset of a schema.org BlogPosting the scraper uses.
+type linkedData struct {
+	Title  string // headline, or name when there is no headline
+	Date   string // datePublished as "January 2, 2006", or ""
+	Author string // author names joined with ", ", or ""
+}
+
+// extractLinkedData returns the first BlogPosting-like object in the page's
+// <script type="application/ld+json"> blocks. Objects may be top level, in
+// an array, or in an @graph. Malformed blocks are ignored; ok is false when
+// none yields a posting.
+func extractLinkedData(doc *goquery.Document) (ld linkedData, ok bool) {
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var v any
+		if json.Unmarshal([]byte(s.Text()), &v) != nil {
+			return true
+		}
+		if obj := findPosting(v); obj != nil {
+			ld = linkedData{
+				Title:  firstString(obj["headline"], obj["name"]),
+				Date:   isoDate(firstString(obj["datePublished"])),
+				Author: authorNames(obj["author"]),
+			}
+			ok = true
+			return false
+		}
+		return true
+	})
+	return ld, ok
+}
+
+func findPosting(v any) map[string]any {
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			if obj := findPosting(item); obj != nil {
+				return obj
+			}
+		}
+	case map[string]any:
+		if isPosting(v["@type"]) {
+			return v
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findPosting(graph)
+		}
+	}
+	return nil
+}
+
+// isPosting reports whether an @type value, a string or an array of them,
+// names a posting type.
+func isPosting(t any) bool {
+	switch t := t.(type) {
+	case string:
+		return postingTypes[t]
+	case []any:
+		for _, s := range t {
+			if s, ok := s.(string); ok && postingTypes[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// firstString returns the first non-empty string among vs.
+func firstString(vs ...any) string {
+	for _, v := range vs {
+		if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// authorNames reads an author given as a name, a Person object, or an array
+// of either.
+func authorNames(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return firstString(v["name"])
+	case []any:
+		var names []string
+		for _, a := range v {
+			if name := authorNames(a); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// isoDate converts an ISO 8601 date or timestamp to "January 2, 2006", or
+// returns "" if it doesn't parse.
+func isoDate(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Format("January 2, 2006")
+	}
+	if len(s) >= 10 {
+		if t, err := time.Parse("2006-01-02", s[:10]); err == nil {
+			return t.Format("January 2, 2006")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Inline Markdown links to absolute http(s) URLs or site-relative paths.
+// Images are matched too; a missing image is as broken as a missing page.
+var reMarkdownLink = regexp.MustCompile(`\]\(((?:https?://|/)[^)\s]+)`)
+
+// LinkSource is an archived post whose links are checked.
+type LinkSource struct {
+	Slug     string
+	Markdown string
+}
+
+// ArchiveLinkSources reads the posts of the Markdown or JSON archive at path.
+func ArchiveLinkSources(archivePath string, jsonFormat bool) ([]LinkSource, error) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	var sources []LinkSource
+	if jsonFormat {
+		var posts []ArchivePost
+		if err := json.Unmarshal(data, &posts); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", archivePath, err)
+		}
+		for _, p := range posts {
+			sources = append(sources, LinkSource{Slug: p.Slug, Markdown: p.Markdown})
+		}
+		return sources, nil
+	}
+	_, sections := ParseArchive(string(data))
+	for _, sec := range sections {
+		// The Source line is plain text, not a link, so it isn't checked.
+		sources = append(sources, LinkSource{Slug: path.Base(sec.URL), Markdown: sec.Text})
+	}
+	return sources, nil
+}
+
+// ExtractLinks returns the distinct link targets in markdown, in order, with
+// site-relative paths resolved against cfg.BaseURL.
+func ExtractLinks(cfg *Config, markdown string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, m := range reMarkdownLink.FindAllStringSubmatch(markdown, -1) {
+		link := m[1]
+		if strings.HasPrefix(link, "/") {
+			link = cfg.BaseURL + link
+		}
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// LinkResult is the outcome of checking one link: the final HTTP status after
+// redirects, or the request error.
+type LinkResult struct {
+	Status int
+	Err    error
+}
+
+// OK reports whether the link resolved to a 2xx response.
+func (r LinkResult) OK() bool {
+	return r.Err == nil && r.Status/100 == 2
+}
+
+func (r LinkResult) String() string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+}
+
+// CheckLinks requests each URL with cfg.Workers concurrent HEADs, retrying
+// with GET when HEAD doesn't succeed since some servers reject HEAD.
+func CheckLinks(cfg *Config, urls []string) map[string]LinkResult {
+	out := make(map[string]LinkResult, len(urls))
+	var mu sync.Mutex
+	sem := make(chan struct{}, max(cfg.Workers, 1))
+	var wg sync.WaitGroup
+
+	for _, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r := checkLink(cfg, http.MethodHead, u)
+			if !r.OK() {
+				r = checkLink(cfg, http.MethodGet, u)
+			}
+			mu.Lock()
+			out[u] = r
+			mu.Unlock()
+		}(u)
+	}
+	wg.Wait()
+	return out
+}
+
+func checkLink(cfg *Config, method, url string) LinkResult {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return LinkResult{Err: err}
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return LinkResult{Err: err}
+	}
+	resp.Body.Close()
+	return LinkResult{Status: resp.StatusCode}
+}
+
+// LinkReport renders the broken links in results as Markdown, grouped by
+// post slug in alphabetical order. Returns the report and the number of
+// broken links listed.
+func LinkReport(sources []LinkSource, links map[string][]string, results map[string]LinkResult) (string, int) {
+	sorted := append([]LinkSource(nil), sources...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Slug < sorted[j].Slug })
+
+	var body strings.Builder
+	n := 0
+	for _, src := range sorted {
+		var broken []string
+		for _, link := range links[src.Slug] {
+			if r, ok := results[link]; ok && !r.OK() {
+				broken = append(broken, fmt.Sprintf("- <%s> — %s\n", link, r))
+			}
+		}
+		if len(broken) == 0 {
+			continue
+		}
+		body.WriteString(fmt.Sprintf("## %s\n\n", src.Slug))
+		for _, b := range broken {
+			body.WriteString(b)
+		}
+		body.WriteString("\n")
+		n += len(broken)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Link Report\n\n")
+	sb.WriteString(fmt.Sprintf("%d broken links across %d checked URLs in %d posts.\n\n", n, len(results), len(sources)))
+	sb.WriteString(body.String())
+	return sb.String(), n
+}
@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinksReportsBrokenLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/head-rejected":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.BaseURL = srv.URL
+	cfg.HTTPClient = srv.Client()
+
+	src := LinkSource{Slug: "post", Markdown: "See [ok](" + srv.URL + "/ok), [relative](/gone), " +
+		"[again](" + srv.URL + "/ok) and ![img](" + srv.URL + "/head-rejected)."}
+	links := map[string][]string{"post": ExtractLinks(&cfg, src.Markdown)}
+	if len(links["post"]) != 3 {
+		t.Fatalf("ExtractLinks = %q, want 3 distinct links", links["post"])
+	}
+
+	report, n := LinkReport([]LinkSource{src}, links, CheckLinks(&cfg, links["post"]))
+	if n != 1 {
+		t.Errorf("broken = %d, want 1\n%s", n, report)
+	}
+	if !strings.Contains(report, "## post\n\n- <"+srv.URL+"/gone> — 404 Not Found\n") {
+		t.Errorf("report does not list the 404 under its post:\n%s", report)
+	}
+}
@@ -0,0 +1,111 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Post is one entry from the blog listing.
+type Post struct {
+	Title string
+	URL   string
+	Slug  string
+	Date  string // "January 2, 2006" when known from a feed, else ""
+
+	// Validators from a previous scrape; when set, ScrapePost fetches the
+	// post conditionally and reports ErrNotModified if it hasn't changed.
+	Since Validators
+}
+
+// ListPosts returns the posts in listing order (newest first), without
+// duplicates. With cfg.UseFeed it tries the RSS/Atom feed first (see
+// FeedPosts), falling back to walking every listing page when no feed is
+// found.
+func ListPosts(cfg *Config) ([]Post, error) {
+	if cfg.UseFeed {
+		cfg.printf("Fetching blog feed...\n")
+		posts, err := FeedPosts(cfg)
+		if err == nil {
+			cfg.printf("Found %d blog posts.\n", len(posts))
+			cfg.Metrics.setFound(len(posts))
+			return posts, nil
+		}
+		cfg.printf("  %v; falling back to listing pages.\n", err)
+	}
+
+	var posts []Post
+	seen := make(map[string]bool)
+	cfg.printf("Fetching blog listing pages...\n")
+
+	for page := 1; ; page++ {
+		url := cfg.ListingURL()
+		if page > 1 {
+			url = fmt.Sprintf("%s?page=%d", cfg.ListingURL(), page)
+		}
+		cfg.printf("  Fetching page %d...\n", page)
+
+		html, err := FetchPage(cfg, url)
+		if err != nil {
+			return posts, fmt.Errorf("page %d: %w", page, err)
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			return posts, err
+		}
+
+		found := 0
+		doc.Find(`a[href^="/unchained/"]`).Each(func(_ int, s *goquery.Selection) {
+			href, _ := s.Attr("href")
+			href = canonicalPath(href)
+			if href == "/unchained" || strings.Contains(href, "/category/") {
+				return
+			}
+			slug := strings.TrimPrefix(href, "/unchained/")
+			if slug == "" || seen[slug] {
+				return
+			}
+			seen[slug] = true
+			title := strings.TrimSpace(s.Text())
+			if title == "" {
+				title = slug
+			}
+			posts = append(posts, Post{Title: title, URL: cfg.BaseURL + href, Slug: slug})
+			found++
+		})
+
+		// Guards against a broken next-page check fetching the same page
+		// forever: stop when a page adds nothing, or at the page cap.
+		if found == 0 {
+			cfg.printf("  Page %d has no new posts; stopping.\n", page)
+			break
+		}
+		if cfg.MaxPages > 0 && page >= cfg.MaxPages {
+			cfg.printf("  Warning: stopped at the %d-page cap; the listing may be incomplete.\n", cfg.MaxPages)
+			break
+		}
+
+		btn := doc.Find(`button[aria-label="Go to next page"]`)
+		if btn.Length() == 0 {
+			break
+		}
+		if _, disabled := btn.Attr("disabled"); disabled {
+			break
+		}
+	}
+
+	cfg.printf("Found %d blog posts.\n", len(posts))
+	cfg.Metrics.setFound(len(posts))
+	return posts, nil
+}
+
+// canonicalPath strips the query string (e.g. utm_* tracking parameters),
+// fragment and any trailing slash from a site-relative link, so each post has
+// one stable URL and slug.
+func canonicalPath(href string) string {
+	if i := strings.IndexAny(href, "?#"); i >= 0 {
+		href = href[:i]
+	}
+	return strings.TrimRight(href, "/")
+}
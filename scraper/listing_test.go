@@ -0,0 +1,111 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListPostsCanonicalizesURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>
+<a href="/unchained">All Articles</a>
+<a href="/unchained/category/news">News</a>
+<a href="/unchained/first-post?utm_source=newsletter&utm_medium=email">First Post</a>
+<a href="/unchained/first-post">First Post</a>
+<a href="/unchained/second-post/#comments">Second Post</a>
+<button aria-label="Go to next page" disabled>Next</button>
+</body></html>`)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.BaseURL = srv.URL
+	cfg.HTTPClient = srv.Client()
+
+	posts, err := ListPosts(&cfg)
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	want := []Post{
+		{Title: "First Post", URL: srv.URL + "/unchained/first-post", Slug: "first-post"},
+		{Title: "Second Post", URL: srv.URL + "/unchained/second-post", Slug: "second-post"},
+	}
+	if len(posts) != len(want) {
+		t.Fatalf("got %d posts %+v, want %d", len(posts), posts, len(want))
+	}
+	for i := range want {
+		if posts[i] != want[i] {
+			t.Errorf("post %d = %+v, want %+v", i, posts[i], want[i])
+		}
+	}
+}
+
+func TestListPostsPrefersFeed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/unchained", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="alternate" type="application/atom+xml" href="/unchained/atom.xml"></head></html>`)
+	})
+	mux.HandleFunc("/unchained/feed.xml", http.NotFound)
+	var base string
+	mux.HandleFunc("/unchained/atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><title>Second Post</title><link href="%[1]s/unchained/second-post/"/><published>2024-03-05T12:00:00Z</published></entry>
+  <entry><title>First Post</title><link rel="alternate" href="%[1]s/unchained/first-post"/><updated>2024-01-09T08:00:00Z</updated></entry>
+  <entry><title>Elsewhere</title><link href="https://example.com/post"/></entry>
+</feed>`, base)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	base = srv.URL
+
+	cfg := DefaultConfig()
+	cfg.BaseURL = srv.URL
+	cfg.HTTPClient = srv.Client()
+	cfg.UseFeed = true
+
+	posts, err := ListPosts(&cfg)
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	want := []Post{
+		{Title: "Second Post", URL: srv.URL + "/unchained/second-post", Slug: "second-post", Date: "March 5, 2024"},
+		{Title: "First Post", URL: srv.URL + "/unchained/first-post", Slug: "first-post", Date: "January 9, 2024"},
+	}
+	if len(posts) != len(want) {
+		t.Fatalf("got %d posts %+v, want %d", len(posts), posts, len(want))
+	}
+	for i := range want {
+		if posts[i] != want[i] {
+			t.Errorf("post %d = %+v, want %+v", i, posts[i], want[i])
+		}
+	}
+}
+
+func TestListPostsStopsWhenPagesRepeat(t *testing.T) {
+	fetches := 0
+	// Every page is identical and claims a next page, as if next-page
+	// detection had broken.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		fmt.Fprint(w, `<html><body>
+<a href="/unchained/first-post">First Post</a>
+<button aria-label="Go to next page">Next</button>
+</body></html>`)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.BaseURL = srv.URL
+	cfg.HTTPClient = srv.Client()
+
+	posts, err := ListPosts(&cfg)
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	if len(posts) != 1 || fetches != 2 {
+		t.Errorf("got %d posts after %d fetches, want 1 post after 2", len(posts), fetches)
+	}
+}
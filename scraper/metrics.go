@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics accumulates what a run did, for an end-of-run summary that shows
+// when a crawl silently under-collected. Set Config.Metrics to collect them;
+// fetch, ListPosts and ScrapeEach update it. Safe for concurrent use.
+type Metrics struct {
+	mu    sync.Mutex
+	start time.Time
+	m     MetricsSnapshot
+}
+
+// MetricsSnapshot is a point-in-time copy of Metrics.
+type MetricsSnapshot struct {
+	PostsFound int
+	Scraped    int
+	Cached     int // already archived, not fetched
+	Unchanged  int // -refresh 304s
+	Skipped    int // not articles, or not HTML
+	Failed     int
+	Fetches    int // every HTTP GET, listing pages included
+	Bytes      int64
+	FetchTime  time.Duration // summed over fetches
+	Duration   time.Duration
+}
+
+// NewMetrics starts the run clock.
+func NewMetrics() *Metrics {
+	return &Metrics{start: time.Now()}
+}
+
+// AddCached records n posts left out of the scrape because they are already
+// archived.
+func (m *Metrics) AddCached(n int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Cached += n
+}
+
+func (m *Metrics) setFound(n int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.PostsFound = n
+}
+
+func (m *Metrics) addFetch(bytes int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Fetches++
+	m.m.Bytes += int64(bytes)
+	m.m.FetchTime += d
+}
+
+// addResult counts one ScrapePost outcome.
+func (m *Metrics) addResult(r Result) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case errors.Is(r.Err, ErrNotModified):
+		m.m.Unchanged++
+	case errors.Is(r.Err, ErrNotArticle), errors.Is(r.Err, ErrNotHTML):
+		m.m.Skipped++
+	case r.Err != nil:
+		m.m.Failed++
+	default:
+		m.m.Scraped++
+	}
+}
+
+// Snapshot returns the counters so far, with Duration measured to now.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.m
+	s.Duration = time.Since(m.start)
+	return s
+}
+
+// AvgFetch is the mean time per HTTP fetch.
+func (s MetricsSnapshot) AvgFetch() time.Duration {
+	if s.Fetches == 0 {
+		return 0
+	}
+	return s.FetchTime / time.Duration(s.Fetches)
+}
+
+// String renders the snapshot as an aligned summary block.
+func (s MetricsSnapshot) String() string {
+	var sb strings.Builder
+	sb.WriteString("Run summary:\n")
+	row := func(name string, v any) { fmt.Fprintf(&sb, "  %-14s %v\n", name, v) }
+	row("posts found", s.PostsFound)
+	row("scraped", s.Scraped)
+	row("cached", s.Cached)
+	row("unchanged", s.Unchanged)
+	row("skipped", s.Skipped)
+	row("failed", s.Failed)
+	row("downloaded", fmt.Sprintf("%.1f KB in %d fetches", float64(s.Bytes)/1024, s.Fetches))
+	row("avg fetch", s.AvgFetch().Round(time.Millisecond))
+	row("duration", s.Duration.Round(time.Millisecond))
+	return sb.String()
+}
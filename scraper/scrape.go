@@ -0,0 +1,377 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// Result is a scraped post converted to cleaned Markdown. Err is set when
+// the download or conversion failed.
+type Result struct {
+	Slug     string
+	Title    string
+	URL      string
+	Date     string   // "January 2, 2006", or "" if not found
+	Author   string   // from JSON-LD; "" if not found
+	Tags     []string // categories and tags, in page order; nil if none
+	Excerpt  string   // plain-text description from the body; see Excerpt
+	Markdown string
+	Err      error
+	Warning  string // non-fatal problem worth a look, e.g. a short body
+
+	Validators // from the response, for conditional re-fetches
+
+	// ListedSlug is the slug the post was listed under when its URL
+	// redirected to another post's; Slug and URL are then the final ones.
+	// "" when the post did not move.
+	ListedSlug string
+}
+
+var (
+	// Pass empty domain — html-to-markdown v1 mangles full URLs with scheme.
+	// Relative links stay relative; boilerplate cleanup handles them.
+	// The table plugin keeps <table> content (e.g. CVE before/after counts)
+	// as GitHub-flavored pipe tables instead of run-together text.
+	mdConverter = md.NewConverter("", true, nil).Use(plugin.Table())
+
+	reDateText = regexp.MustCompile(`^(?:January|February|March|April|May|June|July|August|September|October|November|December) \d{1,2}, \d{4}$`)
+)
+
+var articleSelectors = []string{
+	"article", ".post-content", ".blog-content", ".article-content", "main", `[role="main"]`,
+}
+
+// ParseSelectors splits a comma-separated list of CSS selectors for
+// Config.Selectors, rejecting invalid ones, which goquery would otherwise
+// treat as matching nothing. Since selector groups also use commas, each
+// list item is a single selector.
+func ParseSelectors(list string) ([]string, error) {
+	var sels []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(s); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sels = append(sels, s)
+	}
+	return sels, nil
+}
+
+// ScrapePost downloads one post and converts its article body to Markdown.
+// When post.Since is set and the server reports the page unchanged, the
+// Result's Err is ErrNotModified. When post.URL redirects, the Result
+// carries the final URL and, for another blog post, its slug.
+func ScrapePost(cfg *Config, post Post) Result {
+	page, err := FetchPageIfModified(cfg, post.URL, post.Since)
+	if err != nil {
+		return Result{Slug: post.Slug, Err: err}
+	}
+	r := ParsePost(cfg, post, strings.NewReader(page.Body))
+	r.Validators = page.Validators
+	if page.URL != "" && page.URL != post.URL {
+		r.URL = page.URL
+		if slug := postSlug(page.URL); slug != "" && slug != post.Slug {
+			r.ListedSlug, r.Slug = post.Slug, slug
+		}
+	}
+	return r
+}
+
+// postSlug returns the slug of a blog post URL, or "" for other URLs.
+func postSlug(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.HasPrefix(u.Path, "/unchained/") || strings.HasPrefix(u.Path, "/unchained/category/") {
+		return ""
+	}
+	return strings.TrimPrefix(canonicalPath(u.Path), "/unchained/")
+}
+
+// ParsePost extracts the title, publish date and cleaned Markdown body of a
+// post from its HTML. It does no I/O beyond reading r, so saved pages can be
+// fed to it directly.
+//
+// The body is the first of cfg.Selectors, then articleSelectors, whose
+// visible text reaches cfg.MinContentLength, falling back to the whole
+// <body>. Text rather than HTML length is measured so markup-heavy fragments
+// such as sidebars lose to the real article; nav, header, footer, script and
+// style elements are dropped either way. The title is the first H1 inside
+// that container, else the page's first H1, else the listing title.
+//
+// When the page embeds schema.org BlogPosting JSON-LD, its headline,
+// datePublished and author take precedence over these heuristics.
+func ParsePost(cfg *Config, post Post, r io.Reader) Result {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return Result{Slug: post.Slug, Err: err}
+	}
+
+	// Prefer the H1 inside the extracted article: some layouts put a
+	// site-name H1 ahead of it. The page's first H1 is the fallback.
+	title := post.Title
+	if h1 := firstH1(doc.Selection); h1 != "" {
+		title = h1
+	}
+
+	// Tags often live in the breadcrumb or header, which are stripped below.
+	tags := extractTags(doc)
+	ld, _ := extractLinkedData(doc)
+
+	var content *goquery.Selection
+	for _, sel := range append(slices.Clip(cfg.Selectors), articleSelectors...) {
+		el := doc.Find(sel)
+		if el.Length() == 0 {
+			continue
+		}
+		// Read the H1 before stripping <header>, which often holds it.
+		articleH1 := firstH1(el.First())
+		el.Find("nav, header, footer, script, style").Remove()
+		if len(strings.TrimSpace(el.First().Text())) >= cfg.MinContentLength {
+			content = el.First()
+			if articleH1 != "" {
+				title = articleH1
+			}
+			break
+		}
+	}
+	if content == nil {
+		content = doc.Find("body")
+		content.Find("nav, header, footer, script, style").Remove()
+	}
+	if ld.Title != "" {
+		title = ld.Title
+	}
+	normalizeCodeLanguages(content)
+	contentHTML, _ := content.Html()
+
+	// Extract publish date: prefer JSON-LD, then the feed's date, then
+	// <time datetime="..."> in ISO format, then <time> text, then scan
+	// paragraphs for "Month DD, YYYY".
+	date := ld.Date
+	if date == "" {
+		date = post.Date
+	}
+	if t := doc.Find("time").First(); date == "" && t.Length() > 0 {
+		if dt, ok := t.Attr("datetime"); ok && dt != "" {
+			if parsed, parseErr := time.Parse("2006-01-02", dt); parseErr == nil {
+				date = parsed.Format("January 2, 2006")
+			} else {
+				date = dt
+			}
+		} else {
+			date = strings.TrimSpace(t.Text())
+		}
+	}
+	if date == "" {
+		doc.Find("p, div, span").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if text := strings.TrimSpace(s.Text()); reDateText.MatchString(text) {
+				date = text
+				return false
+			}
+			return true
+		})
+	}
+
+	if isListingPage(content, date) {
+		return Result{Slug: post.Slug, Err: ErrNotArticle}
+	}
+
+	rawMD, err := mdConverter.ConvertString(contentHTML)
+	if err != nil {
+		return Result{Slug: post.Slug, Err: err}
+	}
+	res := Result{
+		Slug:     post.Slug,
+		Title:    title,
+		URL:      post.URL,
+		Date:     date,
+		Author:   ld.Author,
+		Tags:     tags,
+		Markdown: CleanMarkdown(rawMD, title, cfg.Cleanup),
+	}
+	res.Excerpt = Excerpt(res.Markdown)
+
+	// A near-empty body usually means the wrong element was extracted.
+	if n := len(strings.TrimSpace(res.Markdown)); n < cfg.MinMarkdownLength {
+		err := fmt.Errorf("%w: %d characters after cleanup (minimum %d)", ErrShortBody, n, cfg.MinMarkdownLength)
+		if cfg.Strict {
+			return Result{Slug: post.Slug, Err: err}
+		}
+		res.Warning = err.Error()
+	}
+	return res
+}
+
+// ErrShortBody is the Result.Err, under Config.Strict, for posts whose
+// cleaned Markdown is shorter than Config.MinMarkdownLength.
+var ErrShortBody = errors.New("suspiciously short body")
+
+// extractTags collects a post's topics from <meta property="article:tag">
+// and article:section tags and from links to /unchained/category/ pages,
+// deduplicated case-insensitively.
+func extractTags(doc *goquery.Document) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			return
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	doc.Find(`meta[property="article:section"], meta[property="article:tag"]`).Each(func(_ int, m *goquery.Selection) {
+		add(m.AttrOr("content", ""))
+	})
+	doc.Find(`a[href*="/unchained/category/"]`).Each(func(_ int, a *goquery.Selection) {
+		add(a.Text())
+	})
+	return tags
+}
+
+// ErrNotArticle is the Result.Err for pages that look like category, tag or
+// other listing pages rather than posts.
+var ErrNotArticle = errors.New("not an article (looks like a listing page)")
+
+// minListingLinks is the fewest post links a listing page is expected to have.
+const minListingLinks = 5
+
+// isListingPage reports whether content is mostly links to other posts:
+// at least minListingLinks of them, with link text making up half the
+// visible text, or a third when the page has no publish date.
+func isListingPage(content *goquery.Selection, date string) bool {
+	links := content.Find(`a[href^="/unchained/"]`)
+	if links.Length() < minListingLinks {
+		return false
+	}
+	linkText := 0
+	links.Each(func(_ int, a *goquery.Selection) {
+		linkText += len(strings.TrimSpace(a.Text()))
+	})
+	text := len(strings.TrimSpace(content.Text()))
+	if date == "" {
+		return linkText*3 >= text
+	}
+	return linkText*2 >= text
+}
+
+// normalizeCodeLanguages rewrites each <pre><code> so the code element's
+// class is exactly "language-X". The converter copies that class verbatim
+// into the fence, so highlighter classes ("hljs language-bash") would corrupt
+// it and hints kept elsewhere (data-language, or a class on <pre>) would be
+// lost.
+func normalizeCodeLanguages(content *goquery.Selection) {
+	content.Find("pre").Each(func(_ int, pre *goquery.Selection) {
+		code := pre.Find("code").First()
+		if code.Length() == 0 {
+			return
+		}
+		lang := codeLanguage(code)
+		if lang == "" {
+			lang = codeLanguage(pre)
+		}
+		if lang == "" {
+			code.RemoveAttr("class")
+			return
+		}
+		code.SetAttr("class", "language-"+lang)
+	})
+}
+
+// codeLanguage reads a language hint from data-language / data-lang or a
+// language-X / lang-X class on s.
+func codeLanguage(s *goquery.Selection) string {
+	for _, attr := range []string{"data-language", "data-lang"} {
+		if v := strings.TrimSpace(s.AttrOr(attr, "")); v != "" {
+			return strings.ToLower(v)
+		}
+	}
+	for _, class := range strings.Fields(s.AttrOr("class", "")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(class, prefix); ok && lang != "" {
+				return strings.ToLower(lang)
+			}
+		}
+	}
+	return ""
+}
+
+// firstH1 returns the trimmed text of the first non-empty <h1> within s.
+func firstH1(s *goquery.Selection) string {
+	var text string
+	s.Find("h1").EachWithBreak(func(_ int, h *goquery.Selection) bool {
+		text = strings.TrimSpace(h.Text())
+		return text == ""
+	})
+	return text
+}
+
+// ScrapeEach scrapes posts with cfg.Workers concurrent downloads and calls fn
+// with each successful result, in the order of posts, from the calling
+// goroutine. Failures and unchanged posts are reported to cfg.Progress and
+// skipped.
+//
+// At most cfg.Workers posts are downloaded or waiting to be delivered at any
+// time, so memory stays bounded by the concurrency rather than the number of
+// posts, and fn can write each result out as soon as it is ready.
+func ScrapeEach(cfg *Config, posts []Post, fn func(Result)) {
+	workers := max(cfg.Workers, 1)
+	// One single-use channel per post, queued in input order. The queue's
+	// capacity is what bounds the number of results in flight.
+	queue := make(chan chan Result, workers)
+	var completed atomic.Int32
+
+	go func() {
+		defer close(queue)
+		for _, post := range posts {
+			done := make(chan Result, 1)
+			queue <- done
+			go func(p Post) {
+				r := ScrapePost(cfg, p)
+				cfg.Metrics.addResult(r)
+				n := int(completed.Add(1))
+				if errors.Is(r.Err, ErrNotModified) {
+					cfg.printf("  [%d/%d] unchanged %s\n", n, len(posts), p.Slug)
+				} else if errors.Is(r.Err, ErrNotArticle) || errors.Is(r.Err, ErrNotHTML) {
+					cfg.printf("  [%d/%d] skipped %s: %v\n", n, len(posts), p.Slug, r.Err)
+				} else if r.Err != nil {
+					cfg.printf("  [%d/%d] ERROR %s: %v\n", n, len(posts), p.Slug, r.Err)
+				} else if r.Warning != "" {
+					cfg.printf("  [%d/%d] %s (warning: %s)\n", n, len(posts), p.Slug, r.Warning)
+				} else {
+					cfg.printf("  [%d/%d] %s\n", n, len(posts), p.Slug)
+				}
+				done <- r
+			}(post)
+		}
+	}()
+
+	for done := range queue {
+		if r := <-done; r.Err == nil {
+			fn(r)
+		}
+	}
+}
+
+// ScrapeAll is ScrapeEach collecting the successful results keyed by slug.
+func ScrapeAll(cfg *Config, posts []Post) map[string]Result {
+	out := make(map[string]Result, len(posts))
+	ScrapeEach(cfg, posts, func(r Result) {
+		out[r.Slug] = r
+	})
+	return out
+}
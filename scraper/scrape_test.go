@@ -0,0 +1,609 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string, post Post) Result {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg := DefaultConfig()
+	r := ParsePost(&cfg, post, f)
+	if r.Err != nil {
+		t.Fatalf("ParsePost(%s): %v", name, r.Err)
+	}
+	return r
+}
+
+func TestParsePostArticle(t *testing.T) {
+	post := Post{Title: "Listing Title", URL: "https://chainguard.dev/unchained/zero-cve", Slug: "zero-cve"}
+	r := parseFixture(t, "article.html", post)
+
+	if r.Title != "Zero-CVE Images, Explained" {
+		t.Errorf("Title = %q, want the page H1", r.Title)
+	}
+	if r.Date != "May 7, 2024" {
+		t.Errorf("Date = %q, want %q from <time datetime>", r.Date, "May 7, 2024")
+	}
+	if r.Slug != post.Slug || r.URL != post.URL {
+		t.Errorf("Slug/URL = %q/%q, want %q/%q", r.Slug, r.URL, post.Slug, post.URL)
+	}
+	if !strings.Contains(r.Markdown, "## Why minimal matters") {
+		t.Errorf("Markdown missing article H2:\n%s", r.Markdown)
+	}
+	for _, unwanted := range []string{"# Zero-CVE Images, Explained", "trackPageView", "All Articles", "Share this article"} {
+		if strings.Contains(r.Markdown, unwanted) {
+			t.Errorf("Markdown contains %q:\n%s", unwanted, r.Markdown)
+		}
+	}
+}
+
+func TestParsePostSkipsShortContainer(t *testing.T) {
+	r := parseFixture(t, "short-article.html", Post{Slug: "supply-chain"})
+
+	if strings.Contains(r.Markdown, "Teaser") {
+		t.Errorf("used the short <article> instead of .post-content:\n%s", r.Markdown)
+	}
+	if !strings.Contains(r.Markdown, "post-content container") {
+		t.Errorf("Markdown missing .post-content body:\n%s", r.Markdown)
+	}
+	if r.Date != "June 12, 2023" {
+		t.Errorf("Date = %q, want %q from paragraph scan", r.Date, "June 12, 2023")
+	}
+}
+
+func TestParsePostFallsBackToBody(t *testing.T) {
+	r := parseFixture(t, "no-container.html", Post{Title: "Listing Title", Slug: "plain"})
+
+	if r.Title != "Plain Body Post" {
+		t.Errorf("Title = %q, want %q", r.Title, "Plain Body Post")
+	}
+	if !strings.Contains(r.Markdown, "falls back to the whole body") {
+		t.Errorf("Markdown missing body text:\n%s", r.Markdown)
+	}
+	if strings.Contains(r.Markdown, "Site header") || strings.Contains(r.Markdown, "Footer text") {
+		t.Errorf("Markdown kept header/footer:\n%s", r.Markdown)
+	}
+	if r.Date != "" {
+		t.Errorf("Date = %q, want empty", r.Date)
+	}
+}
+
+func TestParsePostPrefersArticleH1(t *testing.T) {
+	r := parseFixture(t, "layout-h1.html", Post{Title: "Listing Title", Slug: "ci"})
+
+	if r.Title != "Hardening Your CI Pipeline" {
+		t.Errorf("Title = %q, want the article H1, not the layout H1", r.Title)
+	}
+	if strings.Contains(r.Markdown, "# Hardening Your CI Pipeline") {
+		t.Errorf("Markdown still contains the title H1:\n%s", r.Markdown)
+	}
+}
+
+func TestParsePostSkipsMarkupHeavySidebar(t *testing.T) {
+	r := parseFixture(t, "sidebar.html", Post{Slug: "nightly"})
+
+	if r.Title != "Rebuilding Images Nightly" {
+		t.Errorf("Title = %q, want the post-content H1", r.Title)
+	}
+	if !strings.Contains(r.Markdown, "must be chosen as the body") || strings.Contains(r.Markdown, "/unchained/one") {
+		t.Errorf("chose the sidebar over the article:\n%s", r.Markdown)
+	}
+}
+
+func TestParsePostRejectsListingPage(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "listing-page.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg := DefaultConfig()
+	if r := ParsePost(&cfg, Post{Slug: "open-source"}, f); !errors.Is(r.Err, ErrNotArticle) {
+		t.Errorf("Err = %v, want ErrNotArticle", r.Err)
+	}
+}
+
+func TestParsePostKeepsCodeLanguages(t *testing.T) {
+	r := parseFixture(t, "code-blocks.html", Post{Slug: "dockerfile"})
+
+	for _, fence := range []string{"```dockerfile\nFROM cgr.dev/chainguard/go:latest-dev", "```bash\ndocker build", "```\noutput here"} {
+		if !strings.Contains(r.Markdown, fence) {
+			t.Errorf("Markdown missing fence %q:\n%s", fence, r.Markdown)
+		}
+	}
+	if strings.Contains(r.Markdown, "hljs") {
+		t.Errorf("highlighter class leaked into a fence:\n%s", r.Markdown)
+	}
+}
+
+func TestParsePostConvertsTables(t *testing.T) {
+	r := parseFixture(t, "table.html", Post{Slug: "cve-counts"})
+
+	for _, row := range []string{
+		"| Image | Upstream CVEs | Chainguard CVEs | Size (MB) |",
+		"| python | 412 | 0 | 51 |",
+		"| nginx | 134 | 0 | 22 |",
+	} {
+		if !strings.Contains(r.Markdown, row) {
+			t.Errorf("Markdown missing table row %q:\n%s", row, r.Markdown)
+		}
+	}
+}
+
+func TestParsePostExtractsTags(t *testing.T) {
+	r := parseFixture(t, "tags.html", Post{Slug: "sbom"})
+
+	want := []string{"Open Source", "SBOM", "Supply Chain"}
+	if strings.Join(r.Tags, "|") != strings.Join(want, "|") {
+		t.Errorf("Tags = %q, want %q", r.Tags, want)
+	}
+	section := FormatPost(r)
+	if !strings.Contains(section, "*Source: "+r.URL+" | February 14, 2023*\n\n*Tags: Open Source, SBOM, Supply Chain*\n\n") {
+		t.Errorf("FormatPost did not emit a separate Tags line after Source:\n%s", section)
+	}
+
+	untagged := parseFixture(t, "article.html", Post{Slug: "zero-cve"})
+	if untagged.Tags != nil {
+		t.Errorf("Tags = %q for a post without tags, want nil", untagged.Tags)
+	}
+	if strings.Contains(FormatPost(untagged), "*Tags:") {
+		t.Errorf("FormatPost emitted a Tags line for a post without tags")
+	}
+}
+
+func TestScrapeEachKeepsInputOrder(t *testing.T) {
+	article, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Earlier posts respond more slowly, so completion order is reversed.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var i int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/unchained/post-"), "%d", &i)
+		time.Sleep(time.Duration(5-i) * 10 * time.Millisecond)
+		w.Write(article)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.HTTPClient = srv.Client()
+	cfg.Workers = 3
+	var posts []Post
+	for i := 0; i < 5; i++ {
+		slug := fmt.Sprintf("post-%d", i)
+		posts = append(posts, Post{Slug: slug, URL: srv.URL + "/unchained/" + slug})
+	}
+
+	var got []string
+	ScrapeEach(&cfg, posts, func(r Result) {
+		got = append(got, r.Slug)
+	})
+	if want := "post-0 post-1 post-2 post-3 post-4"; strings.Join(got, " ") != want {
+		t.Errorf("delivery order = %q, want %q", got, want)
+	}
+}
+
+// TestWriteEachCheckpointNeverAheadOfArchive interrupts an append at every
+// point it could stop: before each post is accepted and after each is
+// written, the checkpoint on disk must list only posts in the archive.
+func TestWriteEachCheckpointNeverAheadOfArchive(t *testing.T) {
+	article, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(article)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.HTTPClient = srv.Client()
+	cfg.Workers = 2
+	var posts []Post
+	for i := 0; i < 3; i++ {
+		slug := fmt.Sprintf("post-%d", i)
+		posts = append(posts, Post{Slug: slug, URL: srv.URL + "/unchained/" + slug})
+	}
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "archive.md")
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	checks := 0
+	checkOnDisk := func() {
+		checks++
+		archived, err := os.ReadFile(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, err := LoadCheckpoint(checkpointPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for slug, e := range saved {
+			if !strings.Contains(string(archived), e.URL) {
+				t.Errorf("check %d: checkpoint lists %s, which the archive lacks", checks, slug)
+			}
+		}
+	}
+	cp := make(Checkpoint)
+	accept := func(r Result) (Result, bool) {
+		checkOnDisk()
+		cp[r.Slug] = CheckpointEntry{Title: r.Title, URL: r.URL}
+		return r, true
+	}
+	n := WriteEach(&cfg, f, posts, accept, func() {
+		if err := SaveCheckpoint(checkpointPath, cp); err != nil {
+			t.Fatal(err)
+		}
+		checkOnDisk()
+	})
+	if n != len(posts) || checks != 2*len(posts) {
+		t.Errorf("wrote %d posts with %d checks, want %d and %d", n, checks, len(posts), 2*len(posts))
+	}
+}
+
+func TestRebuildFileKeepsArchiveOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.md")
+	if err := os.WriteFile(path, []byte("full archive\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := RebuildFile(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("interrupted")
+	})
+	if err == nil {
+		t.Fatal("RebuildFile succeeded, want the write error")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "full archive\n" {
+		t.Errorf("archive = %q after a failed rebuild, want it untouched", b)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	if err := RebuildFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "rebuilt\n")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "rebuilt\n" {
+		t.Errorf("archive = %q, want the rebuilt one", b)
+	}
+}
+
+func TestAppendJSONInsertsInListingOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.json")
+	var sb strings.Builder
+	out, err := NewJSONArchiveWriter(&sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write(Result{Slug: "a", Title: "A", URL: "https://x/unchained/a"})
+	out.Write(Result{Slug: "c", Title: "C", URL: "https://x/unchained/c"})
+	out.Close()
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	all := []Post{
+		{Slug: "a", URL: "https://x/unchained/a"},
+		{Slug: "b", URL: "https://x/unchained/b"},
+		{Slug: "c", URL: "https://x/unchained/c"},
+	}
+	scraped := map[string]Result{"b": {Slug: "b", Title: "B", URL: "https://x/unchained/b", Markdown: "body"}}
+	n, err := AppendJSON(path, all, scraped, true)
+	if err != nil || n != 1 {
+		t.Fatalf("AppendJSON = %d, %v; want 1, nil", n, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	var posts []ArchivePost
+	if err := json.Unmarshal(data, &posts); err != nil {
+		t.Fatalf("archive is not a JSON array: %v\n%s", err, data)
+	}
+	var slugs []string
+	for _, p := range posts {
+		slugs = append(slugs, p.Slug)
+	}
+	if got := strings.Join(slugs, " "); got != "a b c" {
+		t.Errorf("slugs = %q, want %q", got, "a b c")
+	}
+}
+
+func TestTitleSetDisambiguatesDuplicates(t *testing.T) {
+	titles := NewTitleSet()
+	titles.Add("This Shit Is Hard", "hard-part-1")
+
+	if got, clash := titles.Unique("This Shit Is Hard", "hard-part-1"); got != "This Shit Is Hard" || clash != "" {
+		t.Errorf("same slug: Unique = %q, %q; want title unchanged, no clash", got, clash)
+	}
+	got, clash := titles.Unique("this shit is hard", "hard-part-2")
+	if got != "this shit is hard (hard-part-2)" || clash != "hard-part-1" {
+		t.Errorf("Unique = %q, %q; want slug suffix and clash with hard-part-1", got, clash)
+	}
+	if got, clash := titles.Unique("Another Post", "another"); got != "Another Post" || clash != "" {
+		t.Errorf("distinct title: Unique = %q, %q", got, clash)
+	}
+}
+
+func TestParsePostPrefersJSONLD(t *testing.T) {
+	r := parseFixture(t, "jsonld.html", Post{Slug: "reproducible-builds"})
+
+	if r.Title != "Reproducible Builds, Explained" {
+		t.Errorf("Title = %q, want the JSON-LD headline", r.Title)
+	}
+	if r.Date != "June 18, 2024" {
+		t.Errorf("Date = %q, want the JSON-LD datePublished", r.Date)
+	}
+	if r.Author != "Ada Lovelace, Grace Hopper" {
+		t.Errorf("Author = %q, want both JSON-LD authors", r.Author)
+	}
+
+	plain := parseFixture(t, "article.html", Post{Slug: "zero-cve"})
+	if plain.Author != "" {
+		t.Errorf("Author = %q for a page without JSON-LD, want empty", plain.Author)
+	}
+}
+
+func TestScrapePostConditionalGet(t *testing.T) {
+	article, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 14 Feb 2023 10:00:00 GMT")
+		w.Write(article)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.HTTPClient = srv.Client()
+	post := Post{Slug: "zero-cve", URL: srv.URL + "/unchained/zero-cve"}
+
+	first := ScrapePost(&cfg, post)
+	if first.Err != nil {
+		t.Fatalf("first fetch: %v", first.Err)
+	}
+	if first.ETag != `"v1"` || first.LastModified == "" {
+		t.Errorf("validators = %+v, want the response's ETag and Last-Modified", first.Validators)
+	}
+
+	post.Since = first.Validators
+	if again := ScrapePost(&cfg, post); !errors.Is(again.Err, ErrNotModified) {
+		t.Errorf("conditional fetch Err = %v, want ErrNotModified", again.Err)
+	}
+}
+
+func TestScrapePostRejectsNonHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.7\n\x00\x01binary"))
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.HTTPClient = srv.Client()
+	r := ScrapePost(&cfg, Post{Slug: "deck", URL: srv.URL + "/unchained/deck"})
+	if !errors.Is(r.Err, ErrNotHTML) {
+		t.Errorf("Err = %v, want ErrNotHTML", r.Err)
+	}
+	if r.Markdown != "" {
+		t.Errorf("Markdown = %q, want none for a PDF", r.Markdown)
+	}
+}
+
+func TestScrapePostRecordsRedirect(t *testing.T) {
+	article, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/unchained/old-slug", http.RedirectHandler("/unchained/new-slug", http.StatusMovedPermanently))
+	mux.HandleFunc("/unchained/new-slug", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(article)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.HTTPClient = srv.Client()
+	r := ScrapePost(&cfg, Post{Slug: "old-slug", URL: srv.URL + "/unchained/old-slug"})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.Slug != "new-slug" || r.ListedSlug != "old-slug" || r.URL != srv.URL+"/unchained/new-slug" {
+		t.Errorf("Slug, ListedSlug, URL = %q, %q, %q; want the redirect target recorded", r.Slug, r.ListedSlug, r.URL)
+	}
+
+	cp := Checkpoint{"new-slug": {Aliases: []string{"old-slug"}}}
+	if key, ok := cp.Resolve("old-slug"); !ok || key != "new-slug" {
+		t.Errorf("Resolve(old-slug) = %q, %v; want new-slug via its alias", key, ok)
+	}
+}
+
+func TestScrapeEachRecordsMetrics(t *testing.T) {
+	article, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(article)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.HTTPClient = srv.Client()
+	cfg.Metrics = NewMetrics()
+	posts := []Post{
+		{Slug: "a", URL: srv.URL + "/unchained/a"},
+		{Slug: "b", URL: srv.URL + "/unchained/b"},
+	}
+	ScrapeEach(&cfg, posts, func(Result) {})
+
+	m := cfg.Metrics.Snapshot()
+	if m.Scraped != 2 || m.Fetches != 2 || m.Bytes != int64(2*len(article)) {
+		t.Errorf("metrics = %+v, want 2 scraped in 2 fetches of %d bytes", m, len(article))
+	}
+}
+
+func TestParsePostFlagsShortBody(t *testing.T) {
+	parse := func(strict bool) Result {
+		f, err := os.Open(filepath.Join("testdata", "article.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		cfg := DefaultConfig()
+		cfg.MinMarkdownLength = 10000
+		cfg.Strict = strict
+		return ParsePost(&cfg, Post{Slug: "zero-cve"}, f)
+	}
+
+	if r := parse(false); r.Err != nil || !strings.Contains(r.Warning, "suspiciously short") {
+		t.Errorf("non-strict: Err = %v, Warning = %q; want a short-body warning", r.Err, r.Warning)
+	}
+	if r := parse(true); !errors.Is(r.Err, ErrShortBody) {
+		t.Errorf("strict: Err = %v, want ErrShortBody", r.Err)
+	}
+}
+
+func TestCleanupKeepsPhraseAndTogglesRelated(t *testing.T) {
+	r := parseFixture(t, "related-phrase.html", Post{Slug: "base-image"})
+	if !strings.Contains(r.Markdown, "the related articles in our documentation") {
+		t.Errorf("final paragraph mentioning related articles was cut:\n%s", r.Markdown)
+	}
+	if strings.Contains(r.Markdown, "Another Post") {
+		t.Errorf("Related articles footer was kept:\n%s", r.Markdown)
+	}
+
+	f, err := os.Open(filepath.Join("testdata", "related-phrase.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg := DefaultConfig()
+	cfg.Cleanup.KeepRelated = true
+	kept := ParsePost(&cfg, Post{Slug: "base-image"}, f)
+	if !strings.Contains(kept.Markdown, "Another Post") {
+		t.Errorf("KeepRelated: footer was cut:\n%s", kept.Markdown)
+	}
+}
+
+func TestExcerptSkipsLeadInAndTruncates(t *testing.T) {
+	long := strings.Repeat("Minimal images ship only what the app needs at runtime. ", 6)
+	md := "![](/hero.png)\n\n*Guest post*\n\n## Overview\n\n- a list item that is long enough to count as twelve words if it were prose\n\n" +
+		"Short lead-in.\n\n```\ncode block that is long enough to count as twelve words if it were prose\n```\n\n" +
+		"A [Wolfi](https://wolfi.dev) **based** " + long
+
+	got := Excerpt(md)
+	if !strings.HasPrefix(got, "A Wolfi based Minimal images") {
+		t.Errorf("Excerpt picked the wrong paragraph or kept Markdown: %q", got)
+	}
+	if !strings.HasSuffix(got, "…") || len(got) > excerptLength+len("…") {
+		t.Errorf("Excerpt not truncated to %d bytes with an ellipsis: %d bytes %q", excerptLength, len(got), got)
+	}
+	if strings.HasSuffix(strings.TrimSuffix(got, "…"), " ") {
+		t.Errorf("Excerpt cut mid-space: %q", got)
+	}
+
+	if got := Excerpt("## Heading\n\nJust a short line."); got != "Just a short line." {
+		t.Errorf("short-only post: Excerpt = %q, want the first prose paragraph", got)
+	}
+}
+
+func TestGroupArchiveByMonth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.md")
+	posts := []Result{
+		{Title: "Old", URL: "https://x/unchained/old", Date: "December 3, 2024", Markdown: "Old body."},
+		{Title: "Newer", URL: "https://x/unchained/newer", Date: "January 9, 2025", Markdown: "Newer body."},
+		{Title: "No date", URL: "https://x/unchained/nodate", Markdown: "No date body."},
+		{Title: "Newest", URL: "https://x/unchained/newest", Date: "January 20, 2025", Markdown: "Newest body."},
+	}
+	var sb strings.Builder
+	sb.WriteString(ArchiveHeader("Archive", ""))
+	for _, r := range posts {
+		sb.WriteString(FormatPost(r))
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GroupArchiveByMonth(path); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(string(first), "\n") {
+		if strings.HasPrefix(line, "#") {
+			got = append(got, line)
+		}
+	}
+	want := []string{"# Archive", "## 2025", "### January 2025", "## Newer", "## Newest", "## 2024", "### December 2024", "## Old", "## Undated", "## No date"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("headings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if _, sections := ParseArchive(string(first)); len(sections) != len(posts) {
+		t.Errorf("ParseArchive found %d sections in the grouped archive, want %d", len(sections), len(posts))
+	}
+
+	// Regrouping replaces the old headings rather than stacking new ones.
+	if err := GroupArchiveByMonth(path); err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(second) != string(first) {
+		t.Errorf("regrouping changed the archive:\n%s", second)
+	}
+}
+
+func TestParsePostTriesCustomSelectorsFirst(t *testing.T) {
+	page := `<html><body><main><h1>Site</h1><p>` + strings.Repeat("Navigation-like filler text. ", 20) + `</p></main>
+<div class="entry-body-v2"><h1>Moved Post</h1><p>` + strings.Repeat("The real post body after the redesign. ", 10) + `</p></div></body></html>`
+
+	sels, err := ParseSelectors(" .entry-body-v2 , ")
+	if err != nil || len(sels) != 1 {
+		t.Fatalf("ParseSelectors = %q, %v", sels, err)
+	}
+	cfg := DefaultConfig()
+	cfg.Selectors = sels
+	r := ParsePost(&cfg, Post{Slug: "moved"}, strings.NewReader(page))
+	if r.Title != "Moved Post" || !strings.Contains(r.Markdown, "after the redesign") || strings.Contains(r.Markdown, "filler") {
+		t.Errorf("custom selector not preferred: title %q\n%s", r.Title, r.Markdown)
+	}
+
+	if _, err := ParseSelectors("article, div[class="); err == nil {
+		t.Error("invalid selector: want error")
+	}
+}
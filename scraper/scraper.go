@@ -0,0 +1,171 @@
+// Package scraper crawls the Chainguard Unchained blog and converts its posts
+// to a single Markdown or JSON archive.
+//
+// The pieces are usable on their own: ListPosts walks the paginated listing,
+// ScrapePost, ScrapeEach and ScrapeAll download and convert posts,
+// CleanMarkdown and FormatPost (or FormatPostJSON) produce archive entries,
+// and the Checkpoint and archive helpers support incremental runs.
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Config controls where and how the scraper fetches pages.
+type Config struct {
+	BaseURL   string // site root, e.g. https://chainguard.dev
+	UserAgent string // User-Agent header sent with every request
+	Workers   int    // concurrent post downloads in ScrapeEach
+	UseFeed   bool   // list posts from the RSS/Atom feed when there is one
+	MaxPages  int    // most listing pages ListPosts fetches; 0 means no cap
+	// MinContentLength is the visible text length, in bytes, an article
+	// selector's element must reach to be chosen as the post body.
+	MinContentLength int
+	// MinMarkdownLength is the cleaned Markdown length, in bytes, below
+	// which a post is flagged as suspiciously short: a warning, or an
+	// error when Strict is set.
+	MinMarkdownLength int
+	Strict            bool
+	Cleanup           CleanOptions // which trailing-cut cleanup rules to skip
+	// Selectors are CSS selectors for the post body tried before the
+	// built-in ones, for when the site's markup changes; see ParseSelectors.
+	Selectors  []string
+	HTTPClient *http.Client // client used for all requests
+	Progress   io.Writer    // receives progress lines; nil discards them
+	Metrics    *Metrics     // run counters; nil collects none
+}
+
+// DefaultConfig returns the settings the CLI uses.
+func DefaultConfig() Config {
+	transport, _ := NewTransport("")
+	return Config{
+		BaseURL:           "https://chainguard.dev",
+		UserAgent:         "Mozilla/5.0 (compatible; BlogScraper/1.0)",
+		Workers:           10,
+		MaxPages:          100,
+		MinContentLength:  200,
+		MinMarkdownLength: 200,
+		HTTPClient:        &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}
+}
+
+// NewTransport returns an HTTP transport that sends requests through proxy,
+// or, when proxy is "", through HTTP_PROXY/HTTPS_PROXY as limited by
+// NO_PROXY, like http.DefaultTransport.
+func NewTransport(proxy string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q (want e.g. http://proxy.example.com:3128)", proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return t, nil
+}
+
+// ListingURL returns the URL of the blog's first listing page.
+func (c *Config) ListingURL() string {
+	return c.BaseURL + "/unchained"
+}
+
+func (c *Config) printf(format string, args ...any) {
+	if c.Progress != nil {
+		fmt.Fprintf(c.Progress, format, args...)
+	}
+}
+
+// FetchPage GETs url with the configured User-Agent and returns the body.
+// Responses that aren't HTML fail with ErrNotHTML.
+func FetchPage(cfg *Config, url string) (string, error) {
+	page, err := FetchPageIfModified(cfg, url, Validators{})
+	return page.Body, err
+}
+
+// Validators are the cache validators a server sent with a page, used to
+// re-fetch it conditionally.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Page is a fetched page body with its validators.
+type Page struct {
+	Body string
+	URL  string // final URL, after any redirects
+	Validators
+}
+
+// ErrNotModified is returned by FetchPageIfModified when the server answers
+// 304: the page is unchanged since the validators were issued.
+var ErrNotModified = errors.New("not modified")
+
+// ErrNotHTML is returned by FetchPage and FetchPageIfModified when the
+// response's Content-Type is not HTML, e.g. a post URL that redirects to a
+// PDF or image, so it is skipped instead of parsed as garbage.
+var ErrNotHTML = errors.New("not an HTML page")
+
+// FetchPageIfModified is FetchPage sending If-None-Match and
+// If-Modified-Since from since, when set. Returns ErrNotModified on a 304.
+func FetchPageIfModified(cfg *Config, url string, since Validators) (Page, error) {
+	page, contentType, err := fetch(cfg, url, since)
+	if err != nil {
+		return page, err
+	}
+	if !isHTML(contentType) {
+		return Page{}, fmt.Errorf("%s: %w (Content-Type %s)", url, ErrNotHTML, contentType)
+	}
+	return page, nil
+}
+
+// isHTML reports whether a Content-Type header names an HTML document. A
+// missing header is given the benefit of the doubt.
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// fetch GETs url, conditionally when since is set, and returns the page
+// along with its Content-Type header.
+func fetch(cfg *Config, url string, since Validators) (Page, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return Page{}, "", err
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	if since.ETag != "" {
+		req.Header.Set("If-None-Match", since.ETag)
+	}
+	if since.LastModified != "" {
+		req.Header.Set("If-Modified-Since", since.LastModified)
+	}
+	start := time.Now()
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return Page{}, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return Page{Validators: since}, "", ErrNotModified
+	}
+	body, err := io.ReadAll(resp.Body)
+	cfg.Metrics.addFetch(len(body), time.Since(start))
+	return Page{
+		Body: string(body),
+		URL:  resp.Request.URL.String(),
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+	}, resp.Header.Get("Content-Type"), err
+}